@@ -2,6 +2,7 @@ package api
 
 import (
 	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/middleware"
 	"Kama-Chat/model/request"
 	"Kama-Chat/service"
 	"Kama-Chat/utils/constants"
@@ -27,6 +28,8 @@ func (crc *ChatRoomController) GetCurContactListInChatRoom(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, rspList, ret := crc.chatRoomSrv.GetCurContactListInChatRoom(req)
 	response.JsonBack(c, message, ret, rspList)
 }
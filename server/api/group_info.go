@@ -2,6 +2,7 @@ package api
 
 import (
 	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/middleware"
 	"Kama-Chat/model/request"
 	"Kama-Chat/service"
 	"Kama-Chat/utils/constants"
@@ -28,6 +29,8 @@ func (gic *GroupInfoController) CreateGroup(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := gic.groupInfoSrv.CreateGroup(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -43,6 +46,8 @@ func (gic *GroupInfoController) LoadMyGroup(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, groupList, ret := gic.groupInfoSrv.LoadMyGroup(req)
 	response.JsonBack(c, message, ret, groupList)
 }
@@ -73,6 +78,8 @@ func (gic *GroupInfoController) EnterGroupDirectly(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.ContactId = middleware.GetUserId(c)
 	message, ret := gic.groupInfoSrv.EnterGroupDirectly(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -88,6 +95,8 @@ func (gic *GroupInfoController) LeaveGroup(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.UserId = middleware.GetUserId(c)
 	message, ret := gic.groupInfoSrv.LeaveGroup(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -103,6 +112,8 @@ func (gic *GroupInfoController) DismissGroup(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := gic.groupInfoSrv.DismissGroup(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -169,6 +180,8 @@ func (gic *GroupInfoController) UpdateGroupInfo(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := gic.groupInfoSrv.UpdateGroupInfo(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -199,6 +212,76 @@ func (gic *GroupInfoController) RemoveGroupMembers(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := gic.groupInfoSrv.RemoveGroupMembers(req)
 	response.JsonBack(c, message, ret, nil)
 }
+
+// SetGroupAdmin 设置或取消群管理员
+func (gic *GroupInfoController) SetGroupAdmin(c *gin.Context) {
+	req := &request.SetGroupAdminRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := gic.groupInfoSrv.SetGroupAdmin(req)
+	response.JsonBack(c, message, ret, nil)
+}
+
+// MuteGroupMember 禁言或解除禁言群成员
+func (gic *GroupInfoController) MuteGroupMember(c *gin.Context) {
+	req := &request.MuteGroupMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := gic.groupInfoSrv.MuteGroupMember(req)
+	response.JsonBack(c, message, ret, nil)
+}
+
+// SetGroupMuteAll 开启或关闭全员禁言
+func (gic *GroupInfoController) SetGroupMuteAll(c *gin.Context) {
+	req := &request.SetGroupMuteAllRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := gic.groupInfoSrv.SetGroupMuteAll(req)
+	response.JsonBack(c, message, ret, nil)
+}
+
+// TransferGroupOwner 转让群主
+func (gic *GroupInfoController) TransferGroupOwner(c *gin.Context) {
+	req := &request.TransferGroupOwnerRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := gic.groupInfoSrv.TransferGroupOwner(req)
+	response.JsonBack(c, message, ret, nil)
+}
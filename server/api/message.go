@@ -1,6 +1,7 @@
 package api
 
 import (
+	"Kama-Chat/middleware"
 	"Kama-Chat/model/request"
 	"Kama-Chat/service"
 	"Kama-Chat/utils/constants"
@@ -25,6 +26,8 @@ func (mc *MessageController) GetMessageList(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.UserOneId = middleware.GetUserId(c)
 	message, rsp, ret := mc.messageSrv.GetMessageList(req)
 	response.JsonBack(c, message, ret, rsp)
 }
@@ -39,10 +42,188 @@ func (mc *MessageController) GetGroupMessageList(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, rsp, ret := mc.messageSrv.GetGroupMessageList(req)
 	response.JsonBack(c, message, ret, rsp)
 }
 
+// GetMessageReaders 获取已读某条消息的成员
+func (mc *MessageController) GetMessageReaders(c *gin.Context) {
+	req := &request.GetMessageReadersRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, rsp, ret := mc.messageSrv.GetMessageReaders(req)
+	response.JsonBack(c, message, ret, rsp)
+}
+
+// RecallMessage 撤回消息
+func (mc *MessageController) RecallMessage(c *gin.Context) {
+	req := &request.RecallMessageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := mc.messageSrv.RecallMessage(req)
+	response.JsonBack(c, message, ret, nil)
+}
+
+// EditMessage 编辑消息
+func (mc *MessageController) EditMessage(c *gin.Context) {
+	req := &request.EditMessageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := mc.messageSrv.EditMessage(req)
+	response.JsonBack(c, message, ret, nil)
+}
+
+// GetMessageEditHistory 获取消息的编辑历史
+func (mc *MessageController) GetMessageEditHistory(c *gin.Context) {
+	req := &request.GetMessageEditHistoryRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, rsp, ret := mc.messageSrv.GetMessageEditHistory(req)
+	response.JsonBack(c, message, ret, rsp)
+}
+
+// DeleteMessage 删除消息，只对自己隐藏
+func (mc *MessageController) DeleteMessage(c *gin.Context) {
+	req := &request.DeleteMessageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := mc.messageSrv.DeleteMessage(req)
+	response.JsonBack(c, message, ret, nil)
+}
+
+// PinMessage 置顶群聊消息
+func (mc *MessageController) PinMessage(c *gin.Context) {
+	req := &request.PinMessageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := mc.messageSrv.PinMessage(req)
+	response.JsonBack(c, message, ret, nil)
+}
+
+// UnpinMessage 取消置顶群聊消息
+func (mc *MessageController) UnpinMessage(c *gin.Context) {
+	req := &request.UnpinMessageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := mc.messageSrv.UnpinMessage(req)
+	response.JsonBack(c, message, ret, nil)
+}
+
+// GetPinnedMessageList 获取群聊的置顶消息
+func (mc *MessageController) GetPinnedMessageList(c *gin.Context) {
+	req := &request.GetPinnedMessageListRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, rsp, ret := mc.messageSrv.GetPinnedMessageList(req)
+	response.JsonBack(c, message, ret, rsp)
+}
+
+// GetThreadMessageList 获取群聊话题
+func (mc *MessageController) GetThreadMessageList(c *gin.Context) {
+	req := &request.GetThreadMessageListRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, rsp, ret := mc.messageSrv.GetThreadMessageList(req)
+	response.JsonBack(c, message, ret, rsp)
+}
+
+// GetUnreadMentionList 获取未读的@提醒
+func (mc *MessageController) GetUnreadMentionList(c *gin.Context) {
+	req := &request.OwnlistRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, rsp, ret := mc.messageSrv.GetUnreadMentionList(req)
+	response.JsonBack(c, message, ret, rsp)
+}
+
+// SearchMessage 搜索聊天记录
+func (mc *MessageController) SearchMessage(c *gin.Context) {
+	req := &request.SearchMessageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, rsp, ret := mc.messageSrv.SearchMessage(req)
+	response.JsonBack(c, message, ret, rsp)
+}
+
 // UploadAvatar 上传头像
 func (mc *MessageController) UploadAvatar(c *gin.Context) {
 	message, ret := mc.messageSrv.UploadAvatar(c)
@@ -54,3 +235,9 @@ func (mc *MessageController) UploadFile(c *gin.Context) {
 	message, ret := mc.messageSrv.UploadFile(c)
 	response.JsonBack(c, message, ret, nil)
 }
+
+// UploadVoice 上传语音
+func (mc *MessageController) UploadVoice(c *gin.Context) {
+	message, rsp, ret := mc.messageSrv.UploadVoice(c)
+	response.JsonBack(c, message, ret, rsp)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/middleware"
 	"Kama-Chat/model/request"
 	"Kama-Chat/service"
 	"Kama-Chat/utils/constants"
@@ -27,6 +28,8 @@ func (sc *SessionController) OpenSession(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.SendId = middleware.GetUserId(c)
 	message, sessionId, ret := sc.sessionSrv.OpenSession(req)
 	response.JsonBack(c, message, ret, sessionId)
 }
@@ -42,6 +45,8 @@ func (sc *SessionController) GetUserSessionList(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, sessionList, ret := sc.sessionSrv.GetUserSessionList(req)
 	response.JsonBack(c, message, ret, sessionList)
 }
@@ -57,6 +62,8 @@ func (sc *SessionController) GetGroupSessionList(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, groupList, ret := sc.sessionSrv.GetGroupSessionList(req)
 	response.JsonBack(c, message, ret, groupList)
 }
@@ -72,6 +79,8 @@ func (sc *SessionController) DeleteSession(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := sc.sessionSrv.DeleteSession(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -87,6 +96,8 @@ func (sc *SessionController) CheckOpenSessionAllowed(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.SendId = middleware.GetUserId(c)
 	message, res, ret := sc.sessionSrv.CheckOpenSessionAllowed(req)
 	response.JsonBack(c, message, ret, res)
 }
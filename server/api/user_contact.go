@@ -2,6 +2,7 @@ package api
 
 import (
 	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/middleware"
 	"Kama-Chat/model/request"
 	"Kama-Chat/service"
 	"Kama-Chat/utils/constants"
@@ -27,6 +28,8 @@ func (ucc *UserContactController) GetUserContactList(c *gin.Context) {
 			"message": constants.SYSTEM_ERROR,
 		})
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, userList, ret := ucc.userContactSrv.GetUserContactList(req)
 	response.JsonBack(c, message, ret, userList)
 }
@@ -42,6 +45,8 @@ func (ucc *UserContactController) LoadMyJoinedGroup(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, groupList, ret := ucc.userContactSrv.LoadMyJoinedGroup(req)
 	response.JsonBack(c, message, ret, groupList)
 }
@@ -58,6 +63,8 @@ func (ucc *UserContactController) GetContactInfo(c *gin.Context) {
 		return
 	}
 	log.Println(req)
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, contactInfo, ret := ucc.userContactSrv.GetContactInfo(req)
 	response.JsonBack(c, message, ret, contactInfo)
 }
@@ -73,6 +80,8 @@ func (ucc *UserContactController) DeleteContact(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := ucc.userContactSrv.DeleteContact(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -88,6 +97,8 @@ func (ucc *UserContactController) ApplyContact(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := ucc.userContactSrv.ApplyContact(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -103,6 +114,8 @@ func (ucc *UserContactController) GetNewContactList(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, data, ret := ucc.userContactSrv.GetNewContactList(req)
 	response.JsonBack(c, message, ret, data)
 }
@@ -118,6 +131,11 @@ func (ucc *UserContactController) PassContactApply(c *gin.Context) {
 		})
 		return
 	}
+	// owner_id为群聊id时表示处理加群申请，否则以token中的调用方为准
+	req.OperatorId = middleware.GetUserId(c)
+	if req.OwnerId == "" || req.OwnerId[0] != 'G' {
+		req.OwnerId = req.OperatorId
+	}
 	message, ret := ucc.userContactSrv.PassContactApply(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -133,6 +151,11 @@ func (ucc *UserContactController) RefuseContactApply(c *gin.Context) {
 		})
 		return
 	}
+	// owner_id为群聊id时表示处理加群申请，否则以token中的调用方为准
+	req.OperatorId = middleware.GetUserId(c)
+	if req.OwnerId == "" || req.OwnerId[0] != 'G' {
+		req.OwnerId = req.OperatorId
+	}
 	message, ret := ucc.userContactSrv.RefuseContactApply(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -148,6 +171,8 @@ func (ucc *UserContactController) BlackContact(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := ucc.userContactSrv.BlackContact(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -163,6 +188,8 @@ func (ucc *UserContactController) CancelBlackContact(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := ucc.userContactSrv.CancelBlackContact(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -178,10 +205,29 @@ func (ucc *UserContactController) GetAddGroupList(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, data, ret := ucc.userContactSrv.GetAddGroupList(req)
 	response.JsonBack(c, message, ret, data)
 }
 
+// GetGroupApplyList 获取我管理的所有群聊的待处理加群申请
+func (ucc *UserContactController) GetGroupApplyList(c *gin.Context) {
+	req := &request.OwnlistRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, data, ret := ucc.userContactSrv.GetGroupApplyList(req)
+	response.JsonBack(c, message, ret, data)
+}
+
 // BlackApply 拉黑申请
 func (ucc *UserContactController) BlackApply(c *gin.Context) {
 	req := &request.BlackApplyRequest{}
@@ -193,6 +239,11 @@ func (ucc *UserContactController) BlackApply(c *gin.Context) {
 		})
 		return
 	}
+	// owner_id为群聊id时表示处理加群申请，否则以token中的调用方为准
+	req.OperatorId = middleware.GetUserId(c)
+	if req.OwnerId == "" || req.OwnerId[0] != 'G' {
+		req.OwnerId = req.OperatorId
+	}
 	message, ret := ucc.userContactSrv.BlackApply(req)
 	response.JsonBack(c, message, ret, nil)
 }
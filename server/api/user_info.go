@@ -2,6 +2,7 @@ package api
 
 import (
 	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/middleware"
 	"Kama-Chat/model/request"
 	"Kama-Chat/service"
 	"Kama-Chat/utils/constants"
@@ -60,10 +61,29 @@ func (uic *UserInfoController) UpdateUserInfo(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.Uuid = middleware.GetUserId(c)
 	message, ret := uic.userInfoSrv.UpdateUserInfo(req)
 	response.JsonBack(c, message, ret, nil)
 }
 
+// UpdatePassword 修改密码
+func (uic *UserInfoController) UpdatePassword(c *gin.Context) {
+	req := &request.UpdatePasswordRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	// 调用方身份以token为准
+	req.Uuid = middleware.GetUserId(c)
+	message, ret := uic.userInfoSrv.UpdatePassword(req)
+	response.JsonBack(c, message, ret, nil)
+}
+
 // GetUserInfoList 获取用户列表
 func (uic *UserInfoController) GetUserInfoList(c *gin.Context) {
 	req := &request.GetUserInfoListRequest{}
@@ -75,6 +95,8 @@ func (uic *UserInfoController) GetUserInfoList(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, userList, ret := uic.userInfoSrv.GetUserInfoList(req)
 	response.JsonBack(c, message, ret, userList)
 }
@@ -90,6 +112,8 @@ func (uic *UserInfoController) AbleUsers(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := uic.userInfoSrv.AbleUsers(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -105,6 +129,8 @@ func (uic *UserInfoController) DisableUsers(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := uic.userInfoSrv.DisableUsers(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -135,6 +161,8 @@ func (uic *UserInfoController) DeleteUsers(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := uic.userInfoSrv.DeleteUsers(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -150,6 +178,8 @@ func (uic *UserInfoController) SetAdmin(c *gin.Context) {
 		})
 		return
 	}
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
 	message, ret := uic.userInfoSrv.SetAdmin(req)
 	response.JsonBack(c, message, ret, nil)
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"Kama-Chat/initialize/zlog"
 	"Kama-Chat/lib/chat"
+	"Kama-Chat/middleware"
 	"Kama-Chat/model/request"
 	"Kama-Chat/service"
 	"Kama-Chat/utils/constants"
@@ -42,6 +43,8 @@ func (wss *WssController) WsLogout(c *gin.Context) {
 		})
 		return
 	}
-	message, ret := chat.ClientLogout(req.OwnerId)
+	// 调用方身份以token为准
+	req.OwnerId = middleware.GetUserId(c)
+	message, ret := chat.ClientLogout(req.OwnerId, req.DeviceId)
 	response.JsonBack(c, message, ret, nil)
 }
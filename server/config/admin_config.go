@@ -0,0 +1,6 @@
+package config
+
+type AdminConfig struct {
+	// SuperAdminTelephones 使用这些手机号注册的用户自动成为超级管理员
+	SuperAdminTelephones []string `mapstructure:"super_admin_telephones" json:"super_admin_telephones" yaml:"super_admin_telephones"`
+}
@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+type ChatConfig struct {
+	// AckTimeout 消息推送后等待客户端确认的时间，超时重发，单位秒
+	AckTimeout time.Duration `mapstructure:"ack_timeout" json:"ack_timeout" yaml:"ack_timeout"`
+	// MaxRetries 单条消息在一次连接内的最大重发次数，超过后等待设备重连时补发
+	MaxRetries int `mapstructure:"max_retries" json:"max_retries" yaml:"max_retries"`
+	// ReplayLimit 设备重连时最多补发的消息条数，更早的消息由前端拉取聊天记录获得
+	ReplayLimit int `mapstructure:"replay_limit" json:"replay_limit" yaml:"replay_limit"`
+	// RecallWindow 消息发送后允许撤回的时间，单位秒
+	RecallWindow time.Duration `mapstructure:"recall_window" json:"recall_window" yaml:"recall_window"`
+	// DedupWindow 按客户端消息id去重的时间范围，同一发送者在该时间内重发的消息只保存一次，单位秒
+	DedupWindow int `mapstructure:"dedup_window" json:"dedup_window" yaml:"dedup_window"`
+}
@@ -0,0 +1,8 @@
+package config
+
+type ClusterConfig struct {
+	// Enable 是否以集群方式部署，开启后通过Redis登记在线状态并在节点间转发消息
+	Enable bool `mapstructure:"enable" json:"enable" yaml:"enable"`
+	// NodeId 节点id，每个实例必须不同，为空时启动时随机生成
+	NodeId string `mapstructure:"node_id" json:"node_id" yaml:"node_id"`
+}
@@ -8,4 +8,8 @@ type Config struct {
 	LogConfig       LogConfig       `mapstructure:"log_config" json:"log_config" yaml:"log_config"`
 	KafkaConfig     KafkaConfig     `mapstructure:"kafka_config" json:"kafka_config" yaml:"kafka_config"`
 	StaticSrcConfig StaticSrcConfig `mapstructure:"static_src_config" json:"static_src_config" yaml:"static_src_config"`
+	JwtConfig       JwtConfig       `mapstructure:"jwt_config" json:"jwt_config" yaml:"jwt_config"`
+	AdminConfig     AdminConfig     `mapstructure:"admin_config" json:"admin_config" yaml:"admin_config"`
+	ClusterConfig   ClusterConfig   `mapstructure:"cluster_config" json:"cluster_config" yaml:"cluster_config"`
+	ChatConfig      ChatConfig      `mapstructure:"chat_config" json:"chat_config" yaml:"chat_config"`
 }
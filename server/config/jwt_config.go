@@ -0,0 +1,10 @@
+package config
+
+type JwtConfig struct {
+	SigningKey string `mapstructure:"signing_key" json:"signing_key" yaml:"signing_key"`
+	Issuer     string `mapstructure:"issuer" json:"issuer" yaml:"issuer"`
+	// ExpiresTime token有效期，单位小时
+	ExpiresTime int `mapstructure:"expires_time" json:"expires_time" yaml:"expires_time"`
+	// BufferTime token剩余有效期小于该值时自动续签，单位小时
+	BufferTime int `mapstructure:"buffer_time" json:"buffer_time" yaml:"buffer_time"`
+}
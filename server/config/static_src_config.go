@@ -3,4 +3,5 @@ package config
 type StaticSrcConfig struct {
 	StaticAvatarPath string `mapstructure:"static_avatar_path" json:"static_avatar_path" yaml:"static_avatar_path"`
 	StaticFilePath   string `mapstructure:"static_file_path" json:"static_file_path" yaml:"static_file_path"`
+	StaticVoicePath  string `mapstructure:"static_voice_path" json:"static_voice_path" yaml:"static_voice_path"`
 }
@@ -2,6 +2,7 @@ package internal
 
 const (
 	ConfigEnv         = "GVA_CONFIG"
+	JwtSigningKeyEnv  = "KAMA_CHAT_JWT_SIGNING_KEY" // token签名密钥只从环境变量读取，不写入配置文件
 	ConfigDefaultFile = "/Users/yuyansong/GolandProjects/Kama-Chat/server/config.yaml"
 	//ConfigDefaultFile = "server/config.yaml"
 	ConfigTestFile    = "config.test.yaml"
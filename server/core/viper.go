@@ -1,11 +1,13 @@
 package core
 
 import (
+	"Kama-Chat/config"
 	"Kama-Chat/core/internal"
 	"Kama-Chat/global"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
+	"os"
 )
 
 // Viper 初始化 viper 配置实例。
@@ -30,13 +32,30 @@ func Viper(path ...string) *viper.Viper {
 
 	v.OnConfigChange(func(e fsnotify.Event) {
 		fmt.Println("config file changed:", e.Name)
-		if err = v.Unmarshal(&global.CONFIG); err != nil {
+		if err = unmarshalConfig(v); err != nil {
 			fmt.Println(err)
 		}
 	})
-	if err = v.Unmarshal(&global.CONFIG); err != nil {
+	if err = unmarshalConfig(v); err != nil {
 		panic(err)
 	}
 
 	return v
 }
+
+// unmarshalConfig 解析配置文件到 global.CONFIG，并从环境变量注入token签名密钥
+// 未设置签名密钥时返回错误，避免使用空密钥或默认密钥签发token
+func unmarshalConfig(v *viper.Viper) error {
+	var conf config.Config
+	if err := v.Unmarshal(&conf); err != nil {
+		return err
+	}
+	if key := os.Getenv(internal.JwtSigningKeyEnv); key != "" {
+		conf.JwtConfig.SigningKey = key
+	}
+	if conf.JwtConfig.SigningKey == "" {
+		return fmt.Errorf("未设置token签名密钥，请通过环境变量 %s 配置", internal.JwtSigningKeyEnv)
+	}
+	global.CONFIG = conf
+	return nil
+}
@@ -35,10 +35,85 @@ func InitMysql() {
 	if err != nil {
 		zlog.Fatal(err.Error())
 	}
+	// 记录迁移前是否已有已读进度字段，用于判断是否需要回填
+	hasReadState := GormDB.Migrator().HasTable(&model.Session{}) && GormDB.Migrator().HasColumn(&model.Session{}, "last_read_id")
+	// 记录迁移前是否已有消息序号字段，用于判断是否需要回填
+	hasSeq := GormDB.Migrator().HasTable(&model.Message{}) && GormDB.Migrator().HasColumn(&model.Message{}, "seq")
+	// 会话序号上是唯一索引，已有消息需要先补齐序号才能建立索引
+	if !hasSeq && GormDB.Migrator().HasTable(&model.Message{}) {
+		if err := MigrateMessageSeq(GormDB); err != nil {
+			zlog.Fatal(err.Error())
+		}
+	}
 	// 自动迁移数据库模式，如果没有相应的表，会自动创建
-	err = GormDB.AutoMigrate(&model.UserInfo{}, &model.GroupInfo{}, &model.UserContact{}, &model.Session{}, &model.ContactApply{}, &model.Message{})
+	err = GormDB.AutoMigrate(&model.UserInfo{}, &model.GroupInfo{}, &model.UserContact{}, &model.Session{}, &model.ContactApply{}, &model.Message{}, &model.AdminAuditLog{}, &model.MessageDelivery{}, &model.DeviceCursor{}, &model.MessageEdit{}, &model.MessageHidden{}, &model.MessageReaction{}, &model.MessageMention{}, &model.ConversationSeq{}, &model.GroupMember{}, &model.MessagePin{})
 	// 如果迁移失败，记录错误日志并终止程序
 	if err != nil {
 		zlog.Fatal(err.Error())
 	}
+	// 首次加入已读进度时，把已有会话的进度设为当前最新消息，避免历史消息全部变成未读
+	if !hasReadState {
+		if res := GormDB.Exec("UPDATE session SET last_read_id = (SELECT IFNULL(MAX(id), 0) FROM message)"); res.Error != nil {
+			zlog.Fatal(res.Error.Error())
+		}
+	}
+	// 群成员还保存在群聊的json字段中且群成员表为空时，把成员迁移过来，迁移失败时下次启动会重新迁移
+	if GormDB.Migrator().HasColumn(&model.GroupInfo{}, "members") {
+		var memberCnt int64
+		if res := GormDB.Model(&model.GroupMember{}).Count(&memberCnt); res.Error != nil {
+			zlog.Fatal(res.Error.Error())
+		}
+		if memberCnt == 0 {
+			if err := MigrateGroupMembers(GormDB); err != nil {
+				zlog.Fatal(err.Error())
+			}
+		}
+	}
+}
+
+// MigrateGroupMembers 把群聊json字段中的成员迁移到群成员表，并按成员表重新计算群人数
+// 群主的角色为群主，入群时间取加入群聊时的联系人记录，已经迁移过的成员不会重复插入
+// 在同一个事务中执行，失败时群成员表保持为空，下次启动重新迁移
+func MigrateGroupMembers(db *gorm.DB) error {
+	backfill := []string{
+		"INSERT IGNORE INTO group_member (group_id, user_id, role, inviter_id, nickname, joined_at) " +
+			"SELECT g.uuid, jt.user_id, IF(jt.user_id = g.owner_id, 2, 0), '', '', IFNULL((SELECT MIN(c.created_at) FROM user_contact AS c WHERE c.user_id = jt.user_id AND c.contact_id = g.uuid), g.created_at) " +
+			"FROM group_info AS g, JSON_TABLE(g.members, '$[*]' COLUMNS (user_id CHAR(20) PATH '$')) AS jt WHERE g.members IS NOT NULL",
+		"UPDATE group_info AS g SET g.member_cnt = (SELECT COUNT(*) FROM group_member AS m WHERE m.group_id = g.uuid)",
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, sql := range backfill {
+			if res := tx.Exec(sql); res.Error != nil {
+				return res.Error
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateMessageSeq 首次加入消息序号时，为已有消息加上会话id和会话内序号字段，并按消息自增id补齐
+// 需要在自动迁移建立会话序号唯一索引之前调用
+func MigrateMessageSeq(db *gorm.DB) error {
+	if err := db.AutoMigrate(&model.ConversationSeq{}); err != nil {
+		return err
+	}
+	for _, field := range []string{"ConversationId", "Seq"} {
+		if db.Migrator().HasColumn(&model.Message{}, field) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(&model.Message{}, field); err != nil {
+			return err
+		}
+	}
+	backfill := []string{
+		"UPDATE message SET conversation_id = IF(LEFT(receive_id, 1) = 'G', receive_id, CONCAT(LEAST(send_id, receive_id), '_', GREATEST(send_id, receive_id)))",
+		"UPDATE message AS m JOIN (SELECT id, ROW_NUMBER() OVER (PARTITION BY conversation_id ORDER BY id) AS rn FROM message) AS t ON m.id = t.id SET m.seq = t.rn",
+		"INSERT INTO conversation_seq (conversation_id, seq, updated_at) SELECT conversation_id, MAX(seq), NOW() FROM message GROUP BY conversation_id",
+	}
+	for _, sql := range backfill {
+		if res := db.Exec(sql); res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
 }
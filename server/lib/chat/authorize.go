@@ -0,0 +1,176 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/validate"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 客户端发来的聊天消息在交给传输层之前，由读协程按连接的登录身份和当前的联系人、群聊状态校验
+// 校验不通过的消息不会进入处理流程，错误帧只发给发送消息的设备
+
+// ValidateChatMessage 校验消息的格式和内容，不访问数据库，通过时返回空的错误码
+func ValidateChatMessage(req request.ChatMessageRequest) (string, string) {
+	if req.ReceiveId == "" || (req.ReceiveId[0] != 'U' && req.ReceiveId[0] != 'G') {
+		return enum.ErrInvalidMessage, "接收者不合法"
+	}
+	switch req.Type {
+	case enum.Text:
+		if req.Content == "" {
+			return enum.ErrInvalidMessage, "消息内容不能为空"
+		}
+	case enum.File:
+		if req.Url == "" {
+			return enum.ErrInvalidMessage, "文件消息缺少文件地址"
+		}
+	case enum.Voice:
+		if req.Url == "" {
+			return enum.ErrInvalidMessage, "语音消息缺少语音地址"
+		}
+		if err := ValidateVoice(req.Duration, req.Waveform); err != nil {
+			return enum.ErrInvalidMessage, err.Error()
+		}
+	case enum.AudioOrVideo:
+		if req.ReceiveId[0] != 'U' {
+			return enum.ErrInvalidMessage, "只能与单个用户通话"
+		}
+	default:
+		return enum.ErrInvalidMessage, "未知的消息类型"
+	}
+	return "", ""
+}
+
+// authorize 校验客户端发来的聊天消息，并以连接的登录身份和数据库中的资料填写发送者
+// 客户端填写的发送者昵称和头像不可信，一律以用户资料为准
+func (c *Client) authorize(req *request.ChatMessageRequest) (string, string) {
+	if req.SendId != "" && req.SendId != c.Uuid {
+		return enum.ErrSenderMismatch, "发送者与当前登录用户不一致"
+	}
+	req.SendId = c.Uuid
+	if code, message := ValidateChatMessage(*req); code != "" {
+		return code, message
+	}
+	var sender model.UserInfo
+	if res := dao.GormDB.First(&sender, "uuid = ?", c.Uuid); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return enum.ErrUserDisabled, "账号不存在"
+		}
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	if sender.Status == enum.DISABLE {
+		return enum.ErrUserDisabled, "账号已被禁用，无法发送消息"
+	}
+	req.SendName = sender.Nickname
+	req.SendAvatar = sender.Avatar
+	if req.ReceiveId[0] == 'U' {
+		return authorizePrivate(c.Uuid, req.ReceiveId)
+	}
+	return authorizeGroup(c.Uuid, req.ReceiveId)
+}
+
+// authorizePrivate 单聊要求对方账号正常，且自己与对方是好友、双方都没有拉黑
+func authorizePrivate(userId string, receiveId string) (string, string) {
+	var receiver model.UserInfo
+	if res := dao.GormDB.First(&receiver, "uuid = ?", receiveId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return enum.ErrNotFound, "对方不存在"
+		}
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	if receiver.Status == enum.DISABLE {
+		return enum.ErrUserDisabled, "对方账号已被禁用"
+	}
+	var contact model.UserContact
+	if res := dao.GormDB.First(&contact, "user_id = ? AND contact_id = ?", userId, receiveId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return enum.ErrNotFriend, "对方不是你的好友"
+		}
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	switch contact.Status {
+	case enum.NORMAL_:
+		return "", ""
+	case enum.BLACK:
+		return enum.ErrBlacklisted, "你已拉黑对方，无法发送消息"
+	case enum.BE_BLACK:
+		return enum.ErrBlacklisted, "对方已将你拉黑，无法发送消息"
+	default:
+		return enum.ErrNotFriend, "对方不是你的好友"
+	}
+}
+
+// authorizeGroup 群聊要求群聊正常，自己仍是群成员且没有被禁言
+// 开启全员禁言后只有群主和管理员可以发言
+func authorizeGroup(userId string, groupId string) (string, string) {
+	var group model.GroupInfo
+	// 解散的群聊已被软删除，需要包含已删除的记录才能区分解散和不存在
+	if res := dao.GormDB.Unscoped().First(&group, "uuid = ?", groupId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return enum.ErrNotFound, "群聊不存在"
+		}
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	if group.DeletedAt.Valid || group.Status == enum.DISSOLVE {
+		return enum.ErrGroupDismissed, "群聊已解散"
+	}
+	if group.Status == enum.DISABLE {
+		return enum.ErrGroupDisabled, "群聊已被禁用"
+	}
+	member, _, ret := validate.GetGroupMember(groupId, userId)
+	if ret == -2 {
+		return enum.ErrNotGroupMember, "你不在该群聊中，无法发送消息"
+	} else if ret != 0 {
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	if member.Role >= enum.GROUP_ADMIN {
+		return "", ""
+	}
+	now := time.Now()
+	if validate.IsMuted(member, now) {
+		if !member.MuteUntil.Time.Before(validate.MuteForever) {
+			return enum.ErrMuted, "你已被永久禁言，无法发送消息"
+		}
+		return enum.ErrMuted, fmt.Sprintf("你已被禁言，%s后解除", member.MuteUntil.Time.Format("2006-01-02 15:04:05"))
+	}
+	if member.MuteUntil.Valid {
+		validate.ExpireMute(member)
+	}
+	if group.MuteAll {
+		return enum.ErrGroupMuted, "群聊已开启全员禁言，无法发送消息"
+	}
+	return "", ""
+}
+
+// readError 把查询单条记录的错误转换为错误码，记录不存在时返回not_found
+func readError(err error) (string, string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return enum.ErrNotFound, "消息不存在"
+	}
+	return enum.ErrSystem, constants.SYSTEM_ERROR
+}
+
+// sendError 把错误帧发给当前设备
+func (c *Client) sendError(code string, message string, clientMsgId string) {
+	messageBack := marshalEvent(respond.ErrorEventRespond{
+		Event:       enum.EventError,
+		Code:        code,
+		Message:     message,
+		ClientMsgId: clientMsgId,
+	})
+	if messageBack != nil {
+		SendToClient(c, messageBack)
+	}
+}
@@ -0,0 +1,148 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/zlog"
+	myredis "Kama-Chat/lib/redis"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/constants"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// redis中只缓存每个会话最近的 constants.MESSAGE_CACHE_SIZE 条消息，更早的消息分页时直接查数据库
+
+// appendCachedMessage 把新消息追加到单聊记录缓存，没有缓存时不处理
+// 单聊双方各有一份缓存，都需要追加
+func appendCachedMessage(sendId string, receiveId string, messageRsp respond.GetMessageListRespond) {
+	for _, key := range []string{"message_list_" + sendId + "_" + receiveId, "message_list_" + receiveId + "_" + sendId} {
+		rspString, err := myredis.GetKeyNilIsErr(key)
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				zlog.Error(err.Error())
+			}
+			continue
+		}
+		var rsp []respond.GetMessageListRespond
+		if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+			zlog.Error(err.Error())
+			continue
+		}
+		rsp = append(rsp, messageRsp)
+		if len(rsp) > constants.MESSAGE_CACHE_SIZE {
+			rsp = rsp[len(rsp)-constants.MESSAGE_CACHE_SIZE:]
+		}
+		rspByte, err := json.Marshal(rsp)
+		if err != nil {
+			zlog.Error(err.Error())
+			continue
+		}
+		if err := myredis.SetKeyEx(key, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+}
+
+// appendCachedGroupMessage 把新消息追加到群聊记录缓存，没有缓存时不处理
+func appendCachedGroupMessage(groupId string, messageRsp respond.GetGroupMessageListRespond) {
+	key := "group_messagelist_" + groupId
+	rspString, err := myredis.GetKeyNilIsErr(key)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			zlog.Error(err.Error())
+		}
+		return
+	}
+	var rsp []respond.GetGroupMessageListRespond
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	rsp = append(rsp, messageRsp)
+	if len(rsp) > constants.MESSAGE_CACHE_SIZE {
+		rsp = rsp[len(rsp)-constants.MESSAGE_CACHE_SIZE:]
+	}
+	rspByte, err := json.Marshal(rsp)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if err := myredis.SetKeyEx(key, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// MessageCursor 聊天记录的分页游标，Before、After、Around 为消息uuid，最多只有一个非空
+// AfterSeq 为会话内的消息序号，大于0时优先于其他游标
+type MessageCursor struct {
+	Before   string
+	After    string
+	Around   string
+	AfterSeq int64
+}
+
+// Target 游标指向的消息uuid，同时传了多个时与分页的优先级一致
+func (c MessageCursor) Target() string {
+	switch {
+	case c.After != "":
+		return c.After
+	case c.Before != "":
+		return c.Before
+	default:
+		return c.Around
+	}
+}
+
+// PageLimit 每页条数，未传时使用默认值，超过上限时取上限
+func PageLimit(limit int) int {
+	if limit <= 0 {
+		return constants.MESSAGE_PAGE_SIZE
+	}
+	return min(limit, constants.MESSAGE_PAGE_MAX)
+}
+
+// CachedPage 在缓存的最近消息中定位要获取的一页，返回该页在缓存中的范围
+// 缓存不满 constants.MESSAGE_CACHE_SIZE 条时说明缓存的就是整个会话，否则更早的消息需要查数据库
+// 游标不在缓存中，或者缓存中游标之前的消息不够一页时返回false
+func CachedPage(uuids []string, cursor MessageCursor, limit int) (int, int, bool) {
+	// 按序号增量同步时总是查数据库，序号由数据库分配，以数据库为准
+	if cursor.AfterSeq > 0 {
+		return 0, 0, false
+	}
+	complete := len(uuids) < constants.MESSAGE_CACHE_SIZE
+	indexOf := func(uuid string) int {
+		for i := range uuids {
+			if uuids[i] == uuid {
+				return i
+			}
+		}
+		return -1
+	}
+	switch {
+	case cursor.After != "":
+		idx := indexOf(cursor.After)
+		if idx < 0 {
+			return 0, 0, false
+		}
+		return idx + 1, min(len(uuids), idx+1+limit), true
+	case cursor.Before != "":
+		idx := indexOf(cursor.Before)
+		if idx < 0 || (idx < limit && !complete) {
+			return 0, 0, false
+		}
+		return max(0, idx-limit), idx, true
+	case cursor.Around != "":
+		idx := indexOf(cursor.Around)
+		before := limit / 2
+		if idx < 0 || (idx < before && !complete) {
+			return 0, 0, false
+		}
+		return max(0, idx-before), min(len(uuids), idx+limit-before), true
+	default:
+		if len(uuids) < limit && !complete {
+			return 0, 0, false
+		}
+		return max(0, len(uuids)-limit), len(uuids), true
+	}
+}
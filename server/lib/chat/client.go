@@ -1,36 +1,41 @@
 package chat
 
 import (
-	"Kama-Chat/global"
 	"Kama-Chat/initialize/dao"
 	"Kama-Chat/initialize/zlog"
-	myKafka "Kama-Chat/lib/kafka"
+	myjwt "Kama-Chat/lib/jwt"
 	"Kama-Chat/model"
 	"Kama-Chat/model/request"
 	"Kama-Chat/utils/constants"
 	"Kama-Chat/utils/enum"
-	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"github.com/segmentio/kafka-go"
-	"log"
 	"net/http"
-	"strconv"
+	"sync"
+	"time"
 )
 
 // MessageBack 用于存储回传的消息及其对应的客户端UUID
 type MessageBack struct {
 	Message []byte // 消息内容
 	Uuid    string // 客户端UUID
+	NeedAck bool   // 是否需要客户端确认，通话等实时信令不需要
 }
 
-// Client 代表一个客户端连接
+// Client 代表一个客户端连接，同一用户在不同设备上的连接是不同的Client
 type Client struct {
-	Conn     *websocket.Conn   // WebSocket连接
-	Uuid     string            // 客户端唯一标识UUID
-	SendTo   chan []byte       // 发送给server端的消息通道
-	SendBack chan *MessageBack // 发送给前端的消息通道
+	Conn       *websocket.Conn   // WebSocket连接
+	Uuid       string            // 客户端唯一标识UUID
+	DeviceId   string            // 设备id，同一用户的不同设备互不影响
+	AckEnabled bool              // 客户端是否会发送确认帧，开启后未确认的消息会重发，重连时补发
+	Version    int               // 升级时协商的协议版本
+	SendBack   chan *MessageBack // 发送给前端的消息通道
+
+	pending      map[string]*pendingMessage // 等待确认的消息，以消息uuid为键
+	unsaved      []model.MessageDelivery    // 还没写入数据库的投递状态，定时或收到确认时批量写入
+	pendingMutex sync.Mutex                 // 保护pending和unsaved，读协程处理确认、写协程重发时都会访问
 }
 
 // upgrader 用于将HTTP连接升级为WebSocket连接
@@ -44,10 +49,6 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-var ctx = context.Background()
-
-var messageMode = global.CONFIG.KafkaConfig.MessageMode
-
 // 读取websocket消息并发送给send通道
 func (c *Client) Read() {
 	zlog.Info("ws read goroutine start")
@@ -56,101 +57,252 @@ func (c *Client) Read() {
 		_, jsonMessage, err := c.Conn.ReadMessage() // 阻塞状态
 		if err != nil {
 			zlog.Error(err.Error())
+			// 连接已断开，从在线列表中移除该设备，已经登出的连接不会重复处理
+			c.logout()
 			return // 直接断开websocket
 		} else {
-			var message = request.ChatMessageRequest{}
-			if err := json.Unmarshal(jsonMessage, &message); err != nil {
-				zlog.Error(err.Error())
+			frame, ok := DecodeFrame(c.Version, jsonMessage)
+			if !ok {
+				c.sendError(enum.ErrInvalidFrame, "帧格式不正确", "")
+				continue
 			}
-			log.Println("接受到消息为: ", jsonMessage)
-			if messageMode == "channel" {
-				// 如果server的转发channel没满，先把sendto中的给transmit
-				for len(ChatServer.Transmit) < constants.CHANNEL_SIZE && len(c.SendTo) > 0 {
-					sendToMessage := <-c.SendTo
-					ChatServer.SendMessageToTransmit(sendToMessage)
-				}
-				// 如果server没满，sendto空了，直接给server的transmit
-				if len(ChatServer.Transmit) < constants.CHANNEL_SIZE {
-					ChatServer.SendMessageToTransmit(jsonMessage)
-				} else if len(c.SendTo) < constants.CHANNEL_SIZE {
-					// 如果server满了，直接塞sendto
-					c.SendTo <- jsonMessage
-				} else {
-					// 否则考虑加宽channel size，或者使用kafka
-					if err := c.Conn.WriteMessage(websocket.TextMessage, []byte("由于目前同一时间过多用户发送消息，消息发送失败，请稍后重试")); err != nil {
-						zlog.Error(err.Error())
-					}
-				}
-			} else {
-				if err := myKafka.KafkaService.ChatWriter.WriteMessages(ctx, kafka.Message{
-					Key:   []byte(strconv.Itoa(global.CONFIG.KafkaConfig.Partition)),
-					Value: jsonMessage,
-				}); err != nil {
-					zlog.Error(err.Error())
-				}
-				zlog.Info("已发送消息：" + string(jsonMessage))
+			// 事件帧直接在读协程处理，不进入消息转发流程
+			if frame.Type != enum.FrameMessage {
+				c.handleEvent(frame)
+				continue
 			}
+			c.handleMessage(frame)
 		}
 	}
 }
 
+// handleMessage 校验聊天消息并交给传输层，客户端消息id缺省时使用帧id
+func (c *Client) handleMessage(frame request.FrameRequest) {
+	var message = request.ChatMessageRequest{}
+	if err := json.Unmarshal(frame.Payload, &message); err != nil {
+		zlog.Error(err.Error())
+		c.sendError(enum.ErrInvalidMessage, "消息格式不正确", frame.Id)
+		return
+	}
+	if message.ClientMsgId == "" {
+		message.ClientMsgId = frame.Id
+	}
+	if code, msg := c.authorize(&message); code != "" {
+		c.sendError(code, msg, message.ClientMsgId)
+		return
+	}
+	// 交给传输层的是校验并填写了发送者之后的消息
+	jsonMessage, err := json.Marshal(message)
+	if err != nil {
+		zlog.Error(err.Error())
+		c.sendError(enum.ErrSystem, constants.SYSTEM_ERROR, message.ClientMsgId)
+		return
+	}
+	// 交给传输层，缓冲已满时提示客户端稍后重发
+	if err := ChatServer.Publish(jsonMessage); err != nil {
+		zlog.Error(err.Error())
+		c.sendError(enum.ErrBusy, err.Error(), message.ClientMsgId)
+	}
+}
+
+// handleEvent 处理确认、已读回执、正在输入等事件帧，处理失败时把错误帧发给当前设备
+func (c *Client) handleEvent(frame request.FrameRequest) {
+	var code, msg string
+	switch frame.Type {
+	case enum.EventAck:
+		var ack = request.AckRequest{}
+		if err := json.Unmarshal(frame.Payload, &ack); err != nil {
+			code, msg = enum.ErrInvalidMessage, "确认帧格式不正确"
+			break
+		}
+		code, msg = c.handleAck(ack.MessageIds)
+	case enum.EventRead:
+		var read = request.ReadRequest{}
+		if err := json.Unmarshal(frame.Payload, &read); err != nil {
+			code, msg = enum.ErrInvalidMessage, "已读回执格式不正确"
+			break
+		}
+		code, msg = c.handleRead(read)
+	case enum.EventTyping:
+		var typing = request.TypingRequest{}
+		if err := json.Unmarshal(frame.Payload, &typing); err != nil {
+			code, msg = enum.ErrInvalidMessage, "正在输入事件格式不正确"
+			break
+		}
+		code, msg = c.handleTyping(typing)
+	case enum.EventAddReaction, enum.EventRemoveReaction:
+		var reaction = request.ReactionRequest{}
+		if err := json.Unmarshal(frame.Payload, &reaction); err != nil {
+			code, msg = enum.ErrInvalidMessage, "表情回应格式不正确"
+			break
+		}
+		// v2的payload中不一定带event，以帧类型为准
+		reaction.Event = frame.Type
+		code, msg = c.handleReaction(reaction)
+	case enum.EventPresence:
+		var presence = request.PresenceRequest{}
+		if err := json.Unmarshal(frame.Payload, &presence); err != nil {
+			code, msg = enum.ErrInvalidMessage, "在线状态查询格式不正确"
+			break
+		}
+		code, msg = c.handlePresence(presence)
+	default:
+		zlog.Info(fmt.Sprintf("未知的事件帧：%s", frame.Type))
+		code, msg = enum.ErrUnknownType, fmt.Sprintf("未知的帧类型：%s", frame.Type)
+	}
+	if code != "" {
+		c.sendError(code, msg, frame.Id)
+	}
+}
+
+// writeFrame 按连接的协议版本把消息写入连接，只能在写协程中调用
+func (c *Client) writeFrame(messageBack *MessageBack) error {
+	return c.Conn.WriteMessage(websocket.TextMessage, EncodeFrame(c.Version, messageBack))
+}
+
 // 从send通道读取消息发送给websocket
+// 开启确认的客户端先补发断线期间的消息，之后定时重发超时未确认的消息
+// 所有对连接的写都在这个协程里完成，发送通道关闭后发完剩余消息再关闭连接
 func (c *Client) Write() {
 	zlog.Info("ws write goroutine start")
-	for messageBack := range c.SendBack { // 阻塞状态
-		// 通过 WebSocket 发送消息
-		err := c.Conn.WriteMessage(websocket.TextMessage, messageBack.Message)
-		if err != nil {
+	defer func() {
+		if err := c.Conn.Close(); err != nil {
 			zlog.Error(err.Error())
-			return // 直接断开websocket
 		}
-		// log.Println("已发送消息：", messageBack.Message)
-		// 说明顺利发送，修改状态为已发送
-		if res := dao.GormDB.Model(&model.Message{}).Where("uuid = ?", messageBack.Uuid).Update("status", enum.Sent); res.Error != nil {
-			zlog.Error(res.Error.Error())
+	}()
+	if c.AckEnabled {
+		if err := c.replay(); err != nil {
+			zlog.Error(err.Error())
+			return
 		}
 	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case messageBack, ok := <-c.SendBack: // 阻塞状态
+			if !ok {
+				return
+			}
+			// 通过 WebSocket 发送消息
+			err := c.writeFrame(messageBack)
+			if err != nil {
+				zlog.Error(err.Error())
+				return // 直接断开websocket
+			}
+			if c.AckEnabled && messageBack.NeedAck {
+				// 等待客户端确认后再修改状态
+				c.track(messageBack)
+			} else if messageBack.Uuid != "" {
+				// 说明顺利发送，修改状态为已发送
+				if res := dao.GormDB.Model(&model.Message{}).Where("uuid = ?", messageBack.Uuid).Update("status", enum.Sent); res.Error != nil {
+					zlog.Error(res.Error.Error())
+				}
+			}
+		case <-ticker.C:
+			if !c.AckEnabled {
+				continue
+			}
+			if err := c.retransmit(); err != nil {
+				zlog.Error(err.Error())
+				return
+			}
+		}
+	}
+}
+
+// logout 把客户端交给server登出
+func (c *Client) logout() {
+	ChatServer.SendClientToLogout(c)
+}
+
+// close 关闭发送通道，写协程发完已入队的消息后关闭连接
+// 只能由server在持有锁并把客户端移出在线列表后调用
+func (c *Client) close() {
+	close(c.SendBack)
 }
 
 // NewClientInit 当接受到前端有登录消息时，会调用该函数
+// 浏览器的websocket无法自定义请求头，token通过查询参数 token 传入，
+// 只有token校验通过且其中的uuid与clientId一致时才会升级连接
+// 前端通过查询参数 device_id 标识设备，同一用户的不同设备可以同时在线
+// 查询参数 ack=1 表示客户端会对收到的消息发送确认帧
+// 协议版本通过子协议 kama.v1、kama.v2 协商，不带子协议的按v1处理
 func NewClientInit(c *gin.Context, clientId string) {
-	kafkaConfig := global.CONFIG.KafkaConfig
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	claims, err := myjwt.ParseToken(c.Query("token"))
+	if err != nil || claims.Uuid != clientId {
+		zlog.Error(fmt.Sprintf("用户%s的websocket连接token校验失败", clientId))
+		c.JSON(http.StatusOK, gin.H{
+			"code":    401,
+			"message": "token无效，拒绝连接",
+		})
+		return
+	}
+	version, protocol, ok := NegotiateVersion(websocket.Subprotocols(c.Request))
+	if !ok {
+		zlog.Error(fmt.Sprintf("用户%s的websocket连接协议版本不受支持", clientId))
+		c.JSON(http.StatusOK, gin.H{
+			"code":    400,
+			"message": "不支持的协议版本，拒绝连接",
+		})
+		return
+	}
+	var header http.Header
+	if protocol != "" {
+		header = http.Header{"Sec-Websocket-Protocol": []string{protocol}}
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, header)
 	if err != nil {
 		zlog.Error(err.Error())
+		return
 	}
-	client := &Client{
-		Conn:     conn,
-		Uuid:     clientId,
-		SendTo:   make(chan []byte, constants.CHANNEL_SIZE),
-		SendBack: make(chan *MessageBack, constants.CHANNEL_SIZE),
+	deviceId := c.Query("device_id")
+	if deviceId == "" {
+		deviceId = defaultDeviceId
 	}
-	if kafkaConfig.MessageMode == "channel" {
-		ChatServer.SendClientToLogin(client)
-	} else {
-		KafkaChatServer.SendClientToLogin(client)
+	client := &Client{
+		Conn:       conn,
+		Uuid:       clientId,
+		DeviceId:   deviceId,
+		AckEnabled: c.Query("ack") == "1",
+		Version:    version,
+		SendBack:   make(chan *MessageBack, constants.CHANNEL_SIZE),
+		pending:    make(map[string]*pendingMessage),
 	}
+	ChatServer.SendClientToLogin(client)
 	go client.Read()
 	go client.Write()
 	zlog.Info("ws连接成功")
 }
 
+// SendToUser 由服务端主动给用户的所有设备推送消息，例如已读回执等事件
+func SendToUser(uuid string, messageBack *MessageBack) {
+	ChatServer.SendToUser(uuid, messageBack)
+}
+
+// SendToUsers 由服务端给多个用户推送同一条消息，例如群成员、群管理员
+func SendToUsers(messageBack *MessageBack, uuids ...string) {
+	ChatServer.SendToUsers(messageBack, uuids...)
+}
+
+// SendToClient 由服务端给某一个设备推送消息，例如在线状态查询的回复
+func SendToClient(client *Client, messageBack *MessageBack) {
+	ChatServer.SendToClient(client, messageBack)
+}
+
+// IsOnline 判断用户是否有设备在线，开启集群时包括其他节点上的设备
+func IsOnline(uuid string) bool {
+	return ChatServer.IsOnline(uuid) || ChatServer.onlineElsewhere(uuid)
+}
+
 // ClientLogout 当接受到前端有登出消息时，会调用该函数
-func ClientLogout(clientId string) (string, int) {
-	kafkaConfig := global.CONFIG.KafkaConfig
-	client := ChatServer.Clients[clientId]
-	if client != nil {
-		if kafkaConfig.MessageMode == "channel" {
-			ChatServer.SendClientToLogout(client)
-		} else {
-			KafkaChatServer.SendClientToLogout(client)
-		}
-		if err := client.Conn.Close(); err != nil {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
-		}
-		close(client.SendTo)
-		close(client.SendBack)
+// 只关闭发起登出的设备，连接的关闭由server在处理登出时完成
+func ClientLogout(clientId string, deviceId string) (string, int) {
+	if deviceId == "" {
+		deviceId = defaultDeviceId
+	}
+	if client := ChatServer.GetClient(clientId, deviceId); client != nil {
+		client.logout()
 	}
 	return "退出成功", 0
 }
@@ -0,0 +1,93 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/zlog"
+	"fmt"
+)
+
+// defaultDeviceId 建立连接时没有传device_id的客户端使用的设备id
+// 老版本前端不传device_id，同一用户的这类连接仍然互相顶替，与之前单连接的行为一致
+const defaultDeviceId = "default"
+
+// ClientSet 在线客户端集合，第一层以用户UUID为键，第二层以设备id为键
+// 同一用户可以同时在多个设备上保持连接，消息会投递到该用户的所有设备
+// ClientSet 本身不加锁，由持有它的Server负责并发保护
+type ClientSet map[string]map[string]*Client
+
+// Add 添加客户端，返回同一设备上被顶替下来的旧连接，没有则返回nil
+func (cs ClientSet) Add(client *Client) *Client {
+	devices, ok := cs[client.Uuid]
+	if !ok {
+		devices = make(map[string]*Client)
+		cs[client.Uuid] = devices
+	}
+	old := devices[client.DeviceId]
+	devices[client.DeviceId] = client
+	if old == client {
+		return nil
+	}
+	return old
+}
+
+// Remove 移除客户端，只有集合中保存的正是该连接时才会移除
+// 避免同一设备重连后，旧连接的登出把新连接删掉
+func (cs ClientSet) Remove(client *Client) bool {
+	devices, ok := cs[client.Uuid]
+	if !ok || devices[client.DeviceId] != client {
+		return false
+	}
+	delete(devices, client.DeviceId)
+	if len(devices) == 0 {
+		delete(cs, client.Uuid)
+	}
+	return true
+}
+
+// Get 获取用户在某个设备上的连接
+func (cs ClientSet) Get(uuid string, deviceId string) *Client {
+	return cs[uuid][deviceId]
+}
+
+// Devices 获取用户所有在线设备的连接
+func (cs ClientSet) Devices(uuid string) []*Client {
+	devices := cs[uuid]
+	clients := make([]*Client, 0, len(devices))
+	for _, client := range devices {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// Online 判断用户是否有设备在线
+func (cs ClientSet) Online(uuid string) bool {
+	return len(cs[uuid]) > 0
+}
+
+// SendToUser 把消息投递到用户的所有在线设备，返回投递成功的设备数
+// 某个设备的发送缓冲已满时丢弃该设备的这条消息，不阻塞其他设备和整个转发流程
+func (cs ClientSet) SendToUser(uuid string, messageBack *MessageBack) int {
+	sent := 0
+	for deviceId, client := range cs[uuid] {
+		select {
+		case client.SendBack <- messageBack:
+			sent++
+		default:
+			zlog.Error(fmt.Sprintf("用户%s设备%s的发送缓冲已满，消息%s未投递", uuid, deviceId, messageBack.Uuid))
+		}
+	}
+	return sent
+}
+
+// SendToDevice 把消息投递到某一个设备，只有集合中保存的正是该连接时才投递
+func (cs ClientSet) SendToDevice(client *Client, messageBack *MessageBack) bool {
+	if cs.Get(client.Uuid, client.DeviceId) != client {
+		return false
+	}
+	select {
+	case client.SendBack <- messageBack:
+		return true
+	default:
+		zlog.Error(fmt.Sprintf("用户%s设备%s的发送缓冲已满，事件未投递", client.Uuid, client.DeviceId))
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"Kama-Chat/global"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/lib/cluster"
+	myredis "Kama-Chat/lib/redis"
+	"Kama-Chat/utils/random"
+	"fmt"
+)
+
+// InitCluster 开启集群时初始化节点，需要在Redis初始化之后、聊天服务器启动之前调用
+func InitCluster() {
+	clusterConfig := global.CONFIG.ClusterConfig
+	if !clusterConfig.Enable {
+		return
+	}
+	nodeId := clusterConfig.NodeId
+	if nodeId == "" {
+		nodeId = "N" + random.GetNowAndLenRandomString(11)
+	}
+	client := myredis.GetClient()
+	node, err := cluster.NewNode(nodeId, cluster.NewRedisPresence(client), cluster.NewRedisBus(client), ChatServer.DeliverFromCluster)
+	if err != nil {
+		zlog.Fatal(err.Error())
+	}
+	ChatServer.SetNode(node)
+	zlog.Info(fmt.Sprintf("集群节点%s已启动", nodeId))
+}
+
+// CloseCluster 注销本节点上所有连接的在线登记并停止接收转发
+func CloseCluster() {
+	ChatServer.CloseNode()
+}
+
+// SetNode 设置集群节点，只能在 Start 之前调用
+func (s *Server) SetNode(node *cluster.Node) {
+	s.node = node
+}
+
+// CloseNode 注销本节点上所有连接的在线登记并停止接收转发，未开启集群时不做任何事
+func (s *Server) CloseNode() {
+	if s.node == nil {
+		return
+	}
+	for _, client := range s.AllClients() {
+		if err := s.node.Offline(client.Uuid, client.DeviceId); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	s.node.Close()
+}
+
+// DeliverFromCluster 投递其他节点转发过来的消息
+func (s *Server) DeliverFromCluster(envelope *cluster.Envelope) {
+	messageBack := &MessageBack{
+		Message: envelope.Message,
+		Uuid:    envelope.MessageUuid,
+		NeedAck: envelope.NeedAck,
+	}
+	s.mutex.Lock()
+	for _, uuid := range envelope.UserIds {
+		s.Clients.SendToUser(uuid, messageBack)
+	}
+	s.mutex.Unlock()
+}
+
+// deliver 把消息投递到这些用户在本节点上的所有设备，开启集群时再转发给持有他们其他连接的节点
+// 转发需要访问Redis，在释放锁之后进行，避免阻塞其他投递以及登录登出；群聊的所有成员一起转发
+func (s *Server) deliver(messageBack *MessageBack, uuids ...string) {
+	s.mutex.Lock()
+	for _, uuid := range uuids {
+		s.Clients.SendToUser(uuid, messageBack)
+	}
+	s.mutex.Unlock()
+	if s.node == nil {
+		return
+	}
+	if err := s.node.Forward(&cluster.Envelope{
+		UserIds:     uuids,
+		MessageUuid: messageBack.Uuid,
+		Message:     messageBack.Message,
+		NeedAck:     messageBack.NeedAck,
+	}); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// clusterOnline 在集群中登记设备连接
+func (s *Server) clusterOnline(client *Client) {
+	if s.node == nil {
+		return
+	}
+	if err := s.node.Online(client.Uuid, client.DeviceId); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// clusterOffline 在集群中注销设备连接
+func (s *Server) clusterOffline(client *Client) {
+	if s.node == nil {
+		return
+	}
+	if err := s.node.Offline(client.Uuid, client.DeviceId); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// onlineElsewhere 判断用户是否在集群的其他节点上有连接，未开启集群时总是false
+func (s *Server) onlineElsewhere(uuid string) bool {
+	if s.node == nil {
+		return false
+	}
+	online, err := s.node.OnlineElsewhere(uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return false
+	}
+	return online
+}
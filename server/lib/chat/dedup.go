@@ -0,0 +1,102 @@
+package chat
+
+import (
+	"Kama-Chat/global"
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	myredis "Kama-Chat/lib/redis"
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/enum"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	// defaultDedupWindow 未配置时按客户端消息id去重的时间范围
+	defaultDedupWindow = 10 * time.Minute
+	// maxClientMsgIdLength 客户端消息id的最大长度，与message表的字段长度一致
+	maxClientMsgIdLength = 64
+)
+
+// dedupWindow 按客户端消息id去重的时间范围，超过后同一个客户端消息id可以再次使用
+func dedupWindow() time.Duration {
+	if window := global.CONFIG.ChatConfig.DedupWindow; window > 0 {
+		return time.Duration(window) * time.Second
+	}
+	return defaultDedupWindow
+}
+
+// duplicateOf 查找同一发送者在去重时间内用相同客户端消息id保存过的消息
+// 通话信令不去重；客户端消息id过长时视为没有传，消息照常保存
+func duplicateOf(req *request.ChatMessageRequest) (model.Message, bool) {
+	if len(req.ClientMsgId) > maxClientMsgIdLength {
+		req.ClientMsgId = ""
+	}
+	var message model.Message
+	if req.ClientMsgId == "" || req.Type == enum.AudioOrVideo {
+		return message, false
+	}
+	if res := dao.GormDB.Where("send_id = ? AND client_msg_id = ? AND created_at > ?", req.SendId, req.ClientMsgId, time.Now().Add(-dedupWindow())).
+		Order("id DESC").First(&message); res.Error != nil {
+		if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			zlog.Error(res.Error.Error())
+		}
+		return message, false
+	}
+	return message, true
+}
+
+// ClaimClientMsg 在去重时间内占用同一发送者的客户端消息id，返回false表示其他节点正在或已经保存了这条消息
+// 多个节点同时收到同一条重发的消息时只有一个能占用成功；没有客户端消息id或Redis不可用时不拦截
+func ClaimClientMsg(sendId string, clientMsgId string) bool {
+	if clientMsgId == "" {
+		return true
+	}
+	ok, err := myredis.SetKeyNX(clientMsgKey(sendId, clientMsgId), "1", dedupWindow())
+	if err != nil {
+		zlog.Error(err.Error())
+		return true
+	}
+	return ok
+}
+
+// releaseClientMsg 消息保存失败时释放占用，客户端重发时可以重新保存
+func releaseClientMsg(sendId string, clientMsgId string) {
+	if clientMsgId == "" {
+		return
+	}
+	if err := myredis.DelKeyIfExists(clientMsgKey(sendId, clientMsgId)); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+func clientMsgKey(sendId string, clientMsgId string) string {
+	return "client_msg_" + sendId + "_" + clientMsgId
+}
+
+// sendAck 把消息的uuid和序号回执给发送者的所有设备，客户端按客户端消息id对应到自己发出的消息
+// 没有带客户端消息id的消息不回执，发送者通过回显的消息得知发送成功
+func sendAck(message model.Message, duplicate bool) {
+	if message.ClientMsgId == "" {
+		return
+	}
+	messageBack := marshalEvent(respond.SendAckEventRespond{
+		Event:       enum.EventSendAck,
+		ClientMsgId: message.ClientMsgId,
+		MessageId:   message.Uuid,
+		ReceiveId:   message.ReceiveId,
+		Seq:         message.Seq,
+		Duplicate:   duplicate,
+	})
+	if messageBack == nil {
+		return
+	}
+	SendToUser(message.SendId, messageBack)
+	if duplicate {
+		zlog.Info(fmt.Sprintf("用户%s重发消息%s，已忽略", message.SendId, message.Uuid))
+	}
+}
@@ -0,0 +1,331 @@
+package chat
+
+import (
+	"Kama-Chat/global"
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/validate"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	// defaultAckTimeout 未配置时等待客户端确认的时间
+	defaultAckTimeout = 5 * time.Second
+	// defaultMaxRetries 未配置时一次连接内的最大重发次数
+	defaultMaxRetries = 3
+	// defaultReplayLimit 未配置时重连补发的最大消息条数
+	defaultReplayLimit = 500
+	// deliveryBatchSize 批量写入投递状态时每条语句的最大行数
+	deliveryBatchSize = 100
+)
+
+// pendingMessage 已推送但还没有收到客户端确认的消息
+type pendingMessage struct {
+	messageBack *MessageBack
+	sentAt      time.Time
+	retries     int
+}
+
+func ackTimeout() time.Duration {
+	if timeout := global.CONFIG.ChatConfig.AckTimeout; timeout > 0 {
+		return timeout * time.Second
+	}
+	return defaultAckTimeout
+}
+
+func maxRetries() int {
+	if retries := global.CONFIG.ChatConfig.MaxRetries; retries > 0 {
+		return retries
+	}
+	return defaultMaxRetries
+}
+
+func replayLimit() int {
+	if limit := global.CONFIG.ChatConfig.ReplayLimit; limit > 0 {
+		return limit
+	}
+	return defaultReplayLimit
+}
+
+// track 记录已推送的消息，等待客户端确认
+// 该设备的投递状态先记在内存中，由 saveDeliveries 批量写入，推送每条消息时不访问数据库
+func (c *Client) track(messageBack *MessageBack) {
+	now := time.Now()
+	c.pendingMutex.Lock()
+	c.pending[messageBack.Uuid] = &pendingMessage{messageBack: messageBack, sentAt: now}
+	c.unsaved = append(c.unsaved, model.MessageDelivery{
+		MessageUuid: messageBack.Uuid,
+		UserId:      c.Uuid,
+		DeviceId:    c.DeviceId,
+		Status:      enum.DeliveryPending,
+		LastSentAt:  now,
+	})
+	c.pendingMutex.Unlock()
+}
+
+// saveDeliveries 把内存中的投递状态批量写入数据库，acked 中的消息直接记为已确认
+// 确认进度前进之前必须先写入，保证进度之前仍未确认的消息在重连时能按投递状态补发
+// 写入失败时投递状态留在内存中，下次再写
+func (c *Client) saveDeliveries(acked map[string]bool, now time.Time) error {
+	c.pendingMutex.Lock()
+	deliveries := c.unsaved
+	c.unsaved = nil
+	c.pendingMutex.Unlock()
+	if len(deliveries) == 0 {
+		return nil
+	}
+	var pendingList, ackedList []model.MessageDelivery
+	for _, delivery := range deliveries {
+		if acked[delivery.MessageUuid] {
+			delivery.Status = enum.DeliveryAcked
+			delivery.AckedAt = sql.NullTime{Time: now, Valid: true}
+			ackedList = append(ackedList, delivery)
+		} else {
+			pendingList = append(pendingList, delivery)
+		}
+	}
+	columns := []clause.Column{{Name: "message_uuid"}, {Name: "user_id"}, {Name: "device_id"}}
+	err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		// 补发时记录已存在，只更新推送时间
+		if len(pendingList) > 0 {
+			if res := tx.Clauses(clause.OnConflict{
+				Columns:   columns,
+				DoUpdates: clause.AssignmentColumns([]string{"last_sent_at"}),
+			}).CreateInBatches(pendingList, deliveryBatchSize); res.Error != nil {
+				return res.Error
+			}
+		}
+		if len(ackedList) > 0 {
+			if res := tx.Clauses(clause.OnConflict{
+				Columns:   columns,
+				DoUpdates: clause.AssignmentColumns([]string{"status", "last_sent_at", "acked_at"}),
+			}).CreateInBatches(ackedList, deliveryBatchSize); res.Error != nil {
+				return res.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.pendingMutex.Lock()
+		c.unsaved = append(deliveries, c.unsaved...)
+		c.pendingMutex.Unlock()
+	}
+	return err
+}
+
+// retransmit 写入积攒的投递状态，并重发超时未确认的消息，超过最大重发次数的不再重发，留到设备重连时补发
+// 只在写协程中定时调用，返回错误说明连接已不可用
+func (c *Client) retransmit() error {
+	now := time.Now()
+	if err := c.saveDeliveries(nil, now); err != nil {
+		zlog.Error(err.Error())
+	}
+	timeout := ackTimeout()
+	limit := maxRetries()
+	var resend []*pendingMessage
+	c.pendingMutex.Lock()
+	for uuid, pending := range c.pending {
+		if now.Sub(pending.sentAt) < timeout {
+			continue
+		}
+		if pending.retries >= limit {
+			delete(c.pending, uuid)
+			zlog.Info(fmt.Sprintf("消息%s重发%d次仍未确认，等待设备%s重连后补发", uuid, pending.retries, c.DeviceId))
+			continue
+		}
+		pending.retries++
+		pending.sentAt = now
+		resend = append(resend, pending)
+	}
+	c.pendingMutex.Unlock()
+
+	for _, pending := range resend {
+		if err := c.writeFrame(pending.messageBack); err != nil {
+			return err
+		}
+		if res := dao.GormDB.Model(&model.MessageDelivery{}).
+			Where("message_uuid = ? AND user_id = ? AND device_id = ?", pending.messageBack.Uuid, c.Uuid, c.DeviceId).
+			Updates(map[string]interface{}{"retry_count": pending.retries, "last_sent_at": now}); res.Error != nil {
+			zlog.Error(res.Error.Error())
+		}
+	}
+	return nil
+}
+
+// handleAck 处理客户端的确认帧
+// 更新该设备的投递状态和确认进度，接收方确认后消息状态改为已发送
+func (c *Client) handleAck(messageIds []string) (string, string) {
+	if len(messageIds) == 0 {
+		return enum.ErrInvalidMessage, "缺少要确认的消息id"
+	}
+	acked := make(map[string]bool, len(messageIds))
+	c.pendingMutex.Lock()
+	for _, uuid := range messageIds {
+		delete(c.pending, uuid)
+		acked[uuid] = true
+	}
+	c.pendingMutex.Unlock()
+
+	now := time.Now()
+	// 还在内存中的投递状态先写入，再推进确认进度
+	if err := c.saveDeliveries(acked, now); err != nil {
+		zlog.Error(err.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	// 之前已经写入的投递状态改为已确认
+	if res := dao.GormDB.Model(&model.MessageDelivery{}).
+		Where("message_uuid IN ? AND user_id = ? AND device_id = ?", messageIds, c.Uuid, c.DeviceId).
+		Updates(map[string]interface{}{"status": enum.DeliveryAcked, "acked_at": sql.NullTime{Time: now, Valid: true}}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	if res := dao.GormDB.Model(&model.Message{}).
+		Where("uuid IN ? AND send_id != ?", messageIds, c.Uuid).
+		Update("status", enum.Sent); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	var maxId sql.NullInt64
+	if res := dao.GormDB.Model(&model.Message{}).Where("uuid IN ?", messageIds).Select("MAX(id)").Scan(&maxId); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	if !maxId.Valid {
+		return "", ""
+	}
+	var cursor model.DeviceCursor
+	if res := dao.GormDB.First(&cursor, "user_id = ? AND device_id = ?", c.Uuid, c.DeviceId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "", ""
+		}
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	if cursor.LastAckId >= maxId.Int64 {
+		return "", ""
+	}
+	// 进度只越过连续已确认的消息，推送时因缓冲区已满被丢弃的消息没有投递状态，停在它之前，重连时补发
+	related, ok := c.relatedMessages()
+	if !ok {
+		return "", ""
+	}
+	ackedIds := dao.GormDB.Model(&model.MessageDelivery{}).Select("message_uuid").
+		Where("user_id = ? AND device_id = ? AND status = ?", c.Uuid, c.DeviceId, enum.DeliveryAcked)
+	var gapId sql.NullInt64
+	if res := related.Where("id > ? AND id <= ?", cursor.LastAckId, maxId.Int64).
+		Where("uuid NOT IN (?)", ackedIds).
+		Select("MIN(id)").Scan(&gapId); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	lastAckId := maxId.Int64
+	if gapId.Valid {
+		lastAckId = gapId.Int64 - 1
+	}
+	if res := dao.GormDB.Model(&model.DeviceCursor{}).
+		Where("user_id = ? AND device_id = ? AND last_ack_id < ?", c.Uuid, c.DeviceId, lastAckId).
+		Updates(map[string]interface{}{"last_ack_id": lastAckId, "updated_at": now}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	return "", ""
+}
+
+// relatedMessages 该设备需要收到的消息：自己的单聊和所在群聊的消息，不含通话消息和自己删除的消息
+// 补发和推进确认进度使用同一个范围，返回false说明查询用户所在的群聊失败
+func (c *Client) relatedMessages() (*gorm.DB, bool) {
+	groupIds, _, ret := validate.GetJoinedGroupIds(c.Uuid)
+	if ret != 0 {
+		return nil, false
+	}
+	related := dao.GormDB.Where("receive_id = ? OR send_id = ?", c.Uuid, c.Uuid)
+	if len(groupIds) > 0 {
+		related = related.Or("receive_id IN ?", groupIds)
+	}
+	hidden := dao.GormDB.Model(&model.MessageHidden{}).Select("message_uuid").Where("user_id = ?", c.Uuid)
+	return dao.GormDB.Model(&model.Message{}).Where(related).
+		Where("type != ?", enum.AudioOrVideo).
+		Where("uuid NOT IN (?)", hidden), true
+}
+
+// replay 设备重连后补发它错过的消息，只在写协程开始时调用
+// 补发范围是确认进度之后与该用户相关的消息，加上进度之前仍未确认的消息，通话消息不补发
+// 新设备从当前最新的消息开始记录进度，历史消息由前端拉取聊天记录获得
+// 补发与实时推送可能有少量重复，前端按消息uuid去重
+func (c *Client) replay() error {
+	var cursor model.DeviceCursor
+	if res := dao.GormDB.First(&cursor, "user_id = ? AND device_id = ?", c.Uuid, c.DeviceId); res.Error != nil {
+		if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			zlog.Error(res.Error.Error())
+			return nil
+		}
+		var maxId sql.NullInt64
+		if res := dao.GormDB.Model(&model.Message{}).Select("MAX(id)").Scan(&maxId); res.Error != nil {
+			zlog.Error(res.Error.Error())
+			return nil
+		}
+		cursor = model.DeviceCursor{
+			UserId:    c.Uuid,
+			DeviceId:  c.DeviceId,
+			LastAckId: maxId.Int64,
+			UpdatedAt: time.Now(),
+		}
+		if res := dao.GormDB.Clauses(clause.OnConflict{DoNothing: true}).Create(&cursor); res.Error != nil {
+			zlog.Error(res.Error.Error())
+		}
+		return nil
+	}
+
+	related, ok := c.relatedMessages()
+	if !ok {
+		return nil
+	}
+	unacked := dao.GormDB.Model(&model.MessageDelivery{}).Select("message_uuid").
+		Where("user_id = ? AND device_id = ? AND status = ?", c.Uuid, c.DeviceId, enum.DeliveryPending)
+	var messages []model.Message
+	if res := related.
+		Where(dao.GormDB.Where("id > ?", cursor.LastAckId).Or("uuid IN (?)", unacked)).
+		Order("id ASC").Limit(replayLimit()).Find(&messages); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return nil
+	}
+	for _, message := range messages {
+		jsonMessage, err := marshalMessageRespond(message)
+		if err != nil {
+			zlog.Error(err.Error())
+			continue
+		}
+		messageBack := &MessageBack{Message: jsonMessage, Uuid: message.Uuid, NeedAck: true}
+		if err := c.writeFrame(messageBack); err != nil {
+			return err
+		}
+		c.track(messageBack)
+	}
+	if len(messages) > 0 {
+		zlog.Info(fmt.Sprintf("用户%s设备%s重连，补发%d条消息", c.Uuid, c.DeviceId, len(messages)))
+	}
+	return nil
+}
+
+// marshalMessageRespond 按实时推送的格式序列化数据库中的消息
+func marshalMessageRespond(message model.Message) ([]byte, error) {
+	var quote *respond.QuotedMessageRespond
+	if message.ReplyTo != "" {
+		var quoted model.Message
+		if res := dao.GormDB.First(&quoted, "uuid = ?", message.ReplyTo); res.Error == nil {
+			quote = QuoteOf(quoted)
+		}
+	}
+	if message.ReceiveId[0] == 'G' {
+		return json.Marshal(GroupRespond(message, quote))
+	}
+	return json.Marshal(PrivateRespond(message, quote))
+}
@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/validate"
+	"encoding/json"
+	"time"
+)
+
+// attachMentions 校验群聊消息中的@并写入消息，返回需要提醒的用户
+// 只保留群内的其他成员；@所有人只有群主和管理员可以使用，其他人使用时忽略，消息照常发送
+func attachMentions(message *model.Message, req request.ChatMessageRequest) []string {
+	if message.ReceiveId[0] != 'G' || (len(req.Mentions) == 0 && !req.MentionAll) {
+		return nil
+	}
+	members, _, ret := validate.GetGroupMemberIds(message.ReceiveId)
+	if ret != 0 {
+		return nil
+	}
+	isMember := make(map[string]bool, len(members))
+	for _, member := range members {
+		isMember[member] = true
+	}
+	var mentions []string
+	seen := make(map[string]bool, len(req.Mentions))
+	for _, userId := range req.Mentions {
+		if userId != message.SendId && isMember[userId] && !seen[userId] {
+			seen[userId] = true
+			mentions = append(mentions, userId)
+		}
+	}
+	if len(mentions) > 0 {
+		data, err := json.Marshal(mentions)
+		if err != nil {
+			zlog.Error(err.Error())
+			return nil
+		}
+		message.Mentions = string(data)
+	}
+	if req.MentionAll && canMentionAll(message.SendId, message.ReceiveId) {
+		message.MentionAll = true
+		mentions = mentions[:0]
+		for _, member := range members {
+			if member != message.SendId {
+				mentions = append(mentions, member)
+			}
+		}
+	}
+	return mentions
+}
+
+// canMentionAll 判断用户能否在群里@所有人，群主和群管理员可以
+func canMentionAll(userId string, groupId string) bool {
+	member, _, ret := validate.GetGroupMember(groupId, userId)
+	return ret == 0 && member.Role >= enum.GROUP_ADMIN
+}
+
+// saveMentions 消息入库后记录@提醒，用于会话的@计数和未读@列表
+func saveMentions(message model.Message, mentioned []string) {
+	if len(mentioned) == 0 {
+		return
+	}
+	now := time.Now()
+	mentions := make([]model.MessageMention, 0, len(mentioned))
+	for _, userId := range mentioned {
+		mentions = append(mentions, model.MessageMention{
+			MessageId:   message.Id,
+			MessageUuid: message.Uuid,
+			GroupId:     message.ReceiveId,
+			UserId:      userId,
+			IsAll:       message.MentionAll,
+			CreatedAt:   now,
+		})
+	}
+	if res := dao.GormDB.Create(&mentions); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+}
+
+// MentionList 解析消息中@的用户
+func MentionList(message model.Message) []string {
+	if message.Mentions == "" {
+		return nil
+	}
+	var mentions []string
+	if err := json.Unmarshal([]byte(message.Mentions), &mentions); err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return mentions
+}
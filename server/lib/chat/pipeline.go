@@ -0,0 +1,263 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/random"
+	"Kama-Chat/utils/validate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 客户端发来的聊天消息统一按 校验 -> 入库 -> 路由 -> 缓存 的顺序处理，与传输层无关
+// 新的消息类型只需要在 buildMessage 中补充该类型特有的字段
+
+// process 处理传输层交来的一条消息，单条消息处理出错不影响后续消息
+func (s *Server) process(data []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			zlog.Error(fmt.Sprintf("处理消息出错: %v", r))
+		}
+	}()
+	var chatMessageReq request.ChatMessageRequest
+	if err := json.Unmarshal(data, &chatMessageReq); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if chatMessageReq.SendId == "" || chatMessageReq.ReceiveId == "" {
+		zlog.Error("消息缺少发送者或接收者")
+		return
+	}
+	if chatMessageReq.Type == enum.AudioOrVideo {
+		s.processCall(chatMessageReq)
+		return
+	}
+	// 客户端重发的消息不再保存，把第一次保存的结果回执给发送者
+	if original, ok := duplicateOf(&chatMessageReq); ok {
+		sendAck(original, true)
+		return
+	}
+	message, quote, mentioned, err := buildMessage(chatMessageReq)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	// 其他节点正在保存这条重发的消息，由保存的节点回执
+	if !ClaimClientMsg(chatMessageReq.SendId, chatMessageReq.ClientMsgId) {
+		return
+	}
+	// 入库
+	if err := CreateMessage(&message); err != nil {
+		releaseClientMsg(chatMessageReq.SendId, chatMessageReq.ClientMsgId)
+		zlog.Error(err.Error())
+		return
+	}
+	countThreadReply(message)
+	saveMentions(message, mentioned)
+	sendAck(message, false)
+	IndexMessage(message)
+
+	// 路由和缓存，推送的头像使用客户端发来的完整地址，入库的是去掉前缀的路径
+	if message.ReceiveId[0] == 'U' {
+		messageRsp := PrivateRespond(message, quote)
+		messageRsp.SendAvatar = chatMessageReq.SendAvatar
+		if messageBack := marshalMessageBack(message, messageRsp); messageBack != nil {
+			// 发送方的其他设备也需要同步这条消息，前端不自己回显
+			s.route(message, messageBack)
+		}
+		// redis，双方的缓存都需要追加
+		appendCachedMessage(message.SendId, message.ReceiveId, messageRsp)
+	} else {
+		messageRsp := GroupRespond(message, quote)
+		messageRsp.SendAvatar = chatMessageReq.SendAvatar
+		if messageBack := marshalMessageBack(message, messageRsp); messageBack != nil {
+			s.route(message, messageBack)
+		}
+		appendCachedGroupMessage(message.ReceiveId, messageRsp)
+	}
+}
+
+// buildMessage 校验消息并生成要入库的消息，返回被引用消息的摘要和需要@提醒的用户
+func buildMessage(req request.ChatMessageRequest) (model.Message, *respond.QuotedMessageRespond, []string, error) {
+	message := model.Message{
+		Uuid:        fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
+		SessionId:   req.SessionId,
+		Type:        req.Type,
+		SendId:      req.SendId,
+		SendName:    req.SendName,
+		SendAvatar:  normalizePath(req.SendAvatar), // 去除/static之前的所有内容，防止ip前缀引入
+		ReceiveId:   req.ReceiveId,
+		Status:      enum.Unsent,
+		CreatedAt:   time.Now(),
+		ClientMsgId: req.ClientMsgId,
+	}
+	switch req.Type {
+	case enum.Text:
+		if req.Content == "" {
+			return message, nil, nil, errors.New("文本消息内容为空")
+		}
+		message.Content = req.Content
+		message.FileSize = "0B"
+	case enum.File:
+		message.Url = req.Url
+		message.FileSize = req.FileSize
+		message.FileType = req.FileType
+		message.FileName = req.FileName
+	case enum.Voice:
+		// 语音消息和文件消息一样通过url发送，另外带上时长和波形
+		message.Url = req.Url
+		message.FileSize = req.FileSize
+		message.FileType = req.FileType
+		message.FileName = req.FileName
+		if err := attachVoice(&message, req); err != nil {
+			return message, nil, nil, err
+		}
+	default:
+		return message, nil, nil, fmt.Errorf("未知的消息类型%d", req.Type)
+	}
+	quote := attachReply(&message, req.ReplyTo)
+	var mentioned []string
+	if message.Type == enum.Text {
+		mentioned = attachMentions(&message, req)
+	}
+	return message, quote, mentioned, nil
+}
+
+// processCall 处理通话信令，只转发给单聊的对方，不回显、不缓存
+// 发起、接听、拒绝通话由服务端代理时记录到消息表
+func (s *Server) processCall(req request.ChatMessageRequest) {
+	var avData request.AVData
+	if err := json.Unmarshal([]byte(req.AVdata), &avData); err != nil {
+		zlog.Error(err.Error())
+	}
+	message := model.Message{
+		Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
+		SessionId:  req.SessionId,
+		Type:       req.Type,
+		SendId:     req.SendId,
+		SendName:   req.SendName,
+		SendAvatar: req.SendAvatar,
+		ReceiveId:  req.ReceiveId,
+		Status:     enum.Unsent,
+		CreatedAt:  time.Now(),
+		AVdata:     req.AVdata,
+	}
+	if avData.MessageId == "PROXY" && (avData.Type == "start_call" || avData.Type == "receive_call" || avData.Type == "reject_call") {
+		message.SendAvatar = normalizePath(message.SendAvatar)
+		if err := CreateMessage(&message); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	if message.ReceiveId[0] != 'U' {
+		return
+	}
+	jsonMessage, err := json.Marshal(respond.AVMessageRespond{
+		Uuid:       message.Uuid,
+		SendId:     message.SendId,
+		SendName:   message.SendName,
+		SendAvatar: message.SendAvatar,
+		ReceiveId:  message.ReceiveId,
+		Type:       message.Type,
+		CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+		AVdata:     message.AVdata,
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	// 通话这不能回显，发回去的话就会出现两个start_call
+	s.deliver(&MessageBack{Message: jsonMessage, Uuid: message.Uuid}, message.ReceiveId)
+}
+
+// route 把消息投递给会话的所有参与者，单聊为双方，群聊为全体群成员
+func (s *Server) route(message model.Message, messageBack *MessageBack) {
+	if message.ReceiveId[0] == 'U' {
+		// 投递到接收方和发送方的所有在线设备
+		s.deliver(messageBack, message.ReceiveId, message.SendId)
+		return
+	}
+	members, ok := groupMembers(message.ReceiveId)
+	if !ok {
+		return
+	}
+	s.deliver(messageBack, members...)
+}
+
+// groupMembers 获取群成员的uuid
+func groupMembers(groupId string) ([]string, bool) {
+	members, _, ret := validate.GetGroupMemberIds(groupId)
+	return members, ret == 0
+}
+
+// marshalMessageBack 序列化要推送的消息，需要客户端确认
+func marshalMessageBack(message model.Message, messageRsp interface{}) *MessageBack {
+	jsonMessage, err := json.Marshal(messageRsp)
+	if err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return &MessageBack{
+		Message: jsonMessage,
+		Uuid:    message.Uuid,
+		NeedAck: true,
+	}
+}
+
+// PrivateRespond 按推送、缓存和聊天记录的格式生成单聊消息，新的消息字段只需要在这里补充
+func PrivateRespond(message model.Message, quote *respond.QuotedMessageRespond) respond.GetMessageListRespond {
+	return respond.GetMessageListRespond{
+		Uuid:       message.Uuid,
+		SendId:     message.SendId,
+		SendName:   message.SendName,
+		SendAvatar: message.SendAvatar,
+		ReceiveId:  message.ReceiveId,
+		Type:       message.Type,
+		Content:    message.Content,
+		Url:        message.Url,
+		FileSize:   message.FileSize,
+		Duration:   message.Duration,
+		Waveform:   WaveformOf(message),
+		FileName:   message.FileName,
+		FileType:   message.FileType,
+		CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+		Seq:        message.Seq,
+		IsRecalled: message.RecalledAt.Valid,
+		IsEdited:   message.EditedAt.Valid,
+		ReplyTo:    message.ReplyTo,
+		Quote:      quote,
+	}
+}
+
+// GroupRespond 按推送、缓存和聊天记录的格式生成群聊消息，新的消息字段只需要在这里补充
+func GroupRespond(message model.Message, quote *respond.QuotedMessageRespond) respond.GetGroupMessageListRespond {
+	return respond.GetGroupMessageListRespond{
+		Uuid:       message.Uuid,
+		SendId:     message.SendId,
+		SendName:   message.SendName,
+		SendAvatar: message.SendAvatar,
+		ReceiveId:  message.ReceiveId,
+		Type:       message.Type,
+		Content:    message.Content,
+		Url:        message.Url,
+		FileSize:   message.FileSize,
+		Duration:   message.Duration,
+		Waveform:   WaveformOf(message),
+		FileName:   message.FileName,
+		FileType:   message.FileType,
+		CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+		Seq:        message.Seq,
+		IsRecalled: message.RecalledAt.Valid,
+		IsEdited:   message.EditedAt.Valid,
+		ReplyTo:    message.ReplyTo,
+		Quote:      quote,
+		ThreadId:   message.ThreadId,
+		ReplyCount: message.ReplyCount,
+		Mentions:   MentionList(message),
+		MentionAll: message.MentionAll,
+	}
+}
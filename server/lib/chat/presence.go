@@ -0,0 +1,185 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/validate"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 在线状态、正在输入等事件只通过websocket实时推送，不写入message表，也不需要客户端确认
+
+// marshalEvent 序列化事件帧，事件没有消息uuid，写协程不会修改消息状态
+func marshalEvent(event interface{}) *MessageBack {
+	jsonMessage, err := json.Marshal(event)
+	if err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return &MessageBack{Message: jsonMessage}
+}
+
+// noticeEvent 连接建立、退出登录的通知
+func noticeEvent(event string, client *Client, message string) *MessageBack {
+	return marshalEvent(respond.NoticeEventRespond{
+		Event:    event,
+		DeviceId: client.DeviceId,
+		Version:  client.Version,
+		Message:  message,
+	})
+}
+
+// userOnline 用户的第一个设备上线，记录上线时间并通知好友
+// 由server在登录处理中调用，调用时不能持有server的锁
+func userOnline(uuid string) {
+	now := time.Now()
+	if res := dao.GormDB.Model(&model.UserInfo{}).Where("uuid = ?", uuid).
+		Update("last_online_at", sql.NullTime{Time: now, Valid: true}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	notifyContacts(uuid, enum.EventOnline)
+}
+
+// userOffline 用户的最后一个设备下线，记录离线时间并通知好友
+// 由server在登出处理中调用，调用时不能持有server的锁
+func userOffline(uuid string) {
+	now := time.Now()
+	if res := dao.GormDB.Model(&model.UserInfo{}).Where("uuid = ?", uuid).
+		Update("last_offline_at", sql.NullTime{Time: now, Valid: true}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	notifyContacts(uuid, enum.EventOffline)
+}
+
+// notifyContacts 把用户的上下线事件推送给把他当作好友的用户，拉黑、删除的不推送
+func notifyContacts(uuid string, event string) {
+	presence, ok := loadPresence(uuid)
+	if !ok {
+		return
+	}
+	presence.Event = event
+	presence.Online = event == enum.EventOnline
+	messageBack := marshalEvent(presence)
+	if messageBack == nil {
+		return
+	}
+	var contactIds []string
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("contact_id = ? AND contact_type = ? AND status = ?", uuid, enum.USER, enum.NORMAL_).
+		Pluck("user_id", &contactIds); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	SendToUsers(messageBack, contactIds...)
+	zlog.Info(fmt.Sprintf("用户%s%s，已通知%d位好友", uuid, event, len(contactIds)))
+}
+
+// loadPresence 读取用户的上线、离线时间
+func loadPresence(uuid string) (respond.PresenceEventRespond, bool) {
+	var user model.UserInfo
+	if res := dao.GormDB.Select("uuid", "last_online_at", "last_offline_at").First(&user, "uuid = ?", uuid); res.Error != nil {
+		if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			zlog.Error(res.Error.Error())
+		}
+		return respond.PresenceEventRespond{}, false
+	}
+	presence := respond.PresenceEventRespond{UserId: user.Uuid}
+	if user.LastOnlineAt.Valid {
+		presence.LastOnlineAt = user.LastOnlineAt.Time.Format("2006-01-02 15:04:05")
+	}
+	if user.LastOfflineAt.Valid {
+		presence.LastOfflineAt = user.LastOfflineAt.Time.Format("2006-01-02 15:04:05")
+	}
+	return presence, true
+}
+
+// handlePresence 回复查询的联系人在线状态和最近在线时间，只能查询自己的好友
+// 回复只发给发起查询的设备
+func (c *Client) handlePresence(req request.PresenceRequest) (string, string) {
+	if len(req.UserIds) == 0 {
+		return enum.ErrInvalidMessage, "缺少要查询的用户"
+	}
+	var contactIds []string
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("user_id = ? AND contact_id IN ? AND contact_type = ? AND status = ?", c.Uuid, req.UserIds, enum.USER, enum.NORMAL_).
+		Pluck("contact_id", &contactIds); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	for _, contactId := range contactIds {
+		presence, ok := loadPresence(contactId)
+		if !ok {
+			continue
+		}
+		presence.Event = enum.EventPresence
+		presence.Online = IsOnline(contactId)
+		if messageBack := marshalEvent(presence); messageBack != nil {
+			SendToClient(c, messageBack)
+		}
+	}
+	return "", ""
+}
+
+// handleTyping 转发正在输入事件
+// 单聊转发给对方的所有设备，群聊转发给除自己以外的群成员
+func (c *Client) handleTyping(req request.TypingRequest) (string, string) {
+	if req.ReceiveId == "" {
+		return enum.ErrInvalidMessage, "缺少接收者"
+	}
+	messageBack := marshalEvent(respond.TypingEventRespond{
+		Event:     enum.EventTyping,
+		SendId:    c.Uuid,
+		ReceiveId: req.ReceiveId,
+		Typing:    req.Typing,
+	})
+	if messageBack == nil {
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	switch req.ReceiveId[0] {
+	case 'U':
+		var contact model.UserContact
+		if res := dao.GormDB.First(&contact, "user_id = ? AND contact_id = ? AND status = ?", req.ReceiveId, c.Uuid, enum.NORMAL_); res.Error != nil {
+			// 对方已拉黑或删除自己时不转发
+			if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+				zlog.Error(res.Error.Error())
+			}
+			return enum.ErrNotFriend, "对方不是你的好友"
+		}
+		SendToUser(req.ReceiveId, messageBack)
+	case 'G':
+		members, _, ret := validate.GetGroupMemberIds(req.ReceiveId)
+		if ret != 0 {
+			return enum.ErrSystem, constants.SYSTEM_ERROR
+		}
+		isMember := false
+		for _, member := range members {
+			if member == c.Uuid {
+				isMember = true
+				break
+			}
+		}
+		if !isMember {
+			return enum.ErrNotGroupMember, "你不在该群聊中"
+		}
+		receivers := make([]string, 0, len(members))
+		for _, member := range members {
+			if member != c.Uuid {
+				receivers = append(receivers, member)
+			}
+		}
+		SendToUsers(messageBack, receivers...)
+	default:
+		return enum.ErrInvalidMessage, "接收者不合法"
+	}
+	return "", ""
+}
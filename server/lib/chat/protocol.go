@@ -0,0 +1,96 @@
+package chat
+
+import (
+	"Kama-Chat/model/request"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/enum"
+	"encoding/json"
+)
+
+// 协议版本在websocket升级时通过 Sec-WebSocket-Protocol 协商
+// v1 是最初的裸帧格式，带event的是事件帧，否则是聊天消息，不带子协议的旧客户端都按v1处理
+// v2 的每一帧都是 {type, id, payload, error} 信封，错误统一放在 error 中
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2
+)
+
+// subprotocols 支持的子协议与协议版本的对应关系
+var subprotocols = map[string]int{
+	"kama.v1": ProtocolV1,
+	"kama.v2": ProtocolV2,
+}
+
+// NegotiateVersion 按客户端给出的子协议顺序选择第一个支持的版本
+// 客户端没有要求子协议时使用v1，要求了但都不支持时返回false
+func NegotiateVersion(requested []string) (int, string, bool) {
+	if len(requested) == 0 {
+		return ProtocolV1, "", true
+	}
+	for _, protocol := range requested {
+		if version, ok := subprotocols[protocol]; ok {
+			return version, protocol, true
+		}
+	}
+	return 0, "", false
+}
+
+// DecodeFrame 把客户端发来的帧解析为信封，v1的帧按是否带event推断类型
+// v2的帧不是合法的信封时返回false
+func DecodeFrame(version int, data []byte) (request.FrameRequest, bool) {
+	if version != ProtocolV2 {
+		var event = request.WsEventRequest{}
+		if err := json.Unmarshal(data, &event); err != nil || event.Event == "" {
+			return request.FrameRequest{Type: enum.FrameMessage, Payload: data}, true
+		}
+		return request.FrameRequest{Type: event.Event, Payload: data}, true
+	}
+	var frame = request.FrameRequest{}
+	if err := json.Unmarshal(data, &frame); err != nil || frame.Type == "" {
+		return frame, false
+	}
+	return frame, true
+}
+
+// frameHead 服务端推送的帧中用于确定信封类型和id的字段
+type frameHead struct {
+	Event       string `json:"event"`
+	ClientMsgId string `json:"client_msg_id"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+}
+
+// EncodeFrame 按连接的协议版本编码推送给客户端的帧，v1原样发送
+// 聊天消息的id是消息uuid，错误帧和发送回执的id是客户端消息id，其他事件的类型就是事件名
+func EncodeFrame(version int, messageBack *MessageBack) []byte {
+	if version != ProtocolV2 {
+		return messageBack.Message
+	}
+	frame := respond.FrameRespond{Version: ProtocolV2}
+	var head frameHead
+	if err := json.Unmarshal(messageBack.Message, &head); err != nil {
+		// 不是json的内容作为文本通知
+		frame.Type = enum.FrameNotice
+		frame.Payload, _ = json.Marshal(string(messageBack.Message))
+	} else {
+		switch head.Event {
+		case "":
+			frame.Type = enum.FrameMessage
+			frame.Id = messageBack.Uuid
+			frame.Payload = messageBack.Message
+		case enum.EventError:
+			frame.Type = enum.EventError
+			frame.Id = head.ClientMsgId
+			frame.Error = &respond.FrameErrorRespond{Code: head.Code, Message: head.Message}
+		default:
+			frame.Type = head.Event
+			frame.Id = head.ClientMsgId
+			frame.Payload = messageBack.Message
+		}
+	}
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return messageBack.Message
+	}
+	return data
+}
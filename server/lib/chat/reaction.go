@@ -0,0 +1,93 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/validate"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+	"unicode/utf8"
+)
+
+// maxEmojiLength 单个表情回应的最大字符数，组合表情由多个字符组成
+const maxEmojiLength = 8
+
+// handleReaction 处理添加、取消表情回应
+// 只有会话参与者可以回应，已撤回的消息不能回应，变化广播给会话的所有参与者
+func (c *Client) handleReaction(req request.ReactionRequest) (string, string) {
+	if req.MessageId == "" || req.Emoji == "" || utf8.RuneCountInString(req.Emoji) > maxEmojiLength {
+		return enum.ErrInvalidMessage, "缺少消息id或表情不合法"
+	}
+	var message model.Message
+	if res := dao.GormDB.First(&message, "uuid = ?", req.MessageId); res.Error != nil {
+		if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			zlog.Error(res.Error.Error())
+		}
+		return readError(res.Error)
+	}
+	if message.Type == enum.AudioOrVideo || message.RecalledAt.Valid || !isParticipant(c.Uuid, message) {
+		return enum.ErrForbidden, "该消息不能回应"
+	}
+
+	var res *gorm.DB
+	if req.Event == enum.EventAddReaction {
+		res = dao.GormDB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.MessageReaction{
+			MessageUuid: message.Uuid,
+			UserId:      c.Uuid,
+			Emoji:       req.Emoji,
+			CreatedAt:   time.Now(),
+		})
+	} else {
+		res = dao.GormDB.Where("message_uuid = ? AND user_id = ? AND emoji = ?", message.Uuid, c.Uuid, req.Emoji).
+			Delete(&model.MessageReaction{})
+	}
+	if res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	// 重复添加或取消不存在的回应时不广播
+	if res.RowsAffected == 0 {
+		return "", ""
+	}
+	var count int64
+	if res := dao.GormDB.Model(&model.MessageReaction{}).Where("message_uuid = ? AND emoji = ?", message.Uuid, req.Emoji).Count(&count); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	messageBack := marshalEvent(respond.ReactionEventRespond{
+		Event:     req.Event,
+		MessageId: message.Uuid,
+		ReceiveId: message.ReceiveId,
+		UserId:    c.Uuid,
+		Emoji:     req.Emoji,
+		Count:     count,
+	})
+	if messageBack == nil {
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	SendToParticipants(message, messageBack)
+	zlog.Info(fmt.Sprintf("用户%s对消息%s%s %s", c.Uuid, message.Uuid, req.Event, req.Emoji))
+	return "", ""
+}
+
+// isParticipant 判断用户是否是消息所在会话的参与者，群聊要求仍在群中
+func isParticipant(userId string, message model.Message) bool {
+	if message.ReceiveId[0] != 'G' {
+		return userId == message.SendId || userId == message.ReceiveId
+	}
+	_, _, ret := validate.GetGroupMember(message.ReceiveId, userId)
+	return ret == 0
+}
+
+// SendToParticipants 把事件推送给消息所在会话的所有参与者，单聊为双方，群聊为全体群成员
+func SendToParticipants(message model.Message, messageBack *MessageBack) {
+	ChatServer.route(message, messageBack)
+}
@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// handleRead 处理客户端的已读回执
+// 推进该用户在会话上的已读进度，单聊把对方发来的消息标记为已读，并通知相关设备
+func (c *Client) handleRead(req request.ReadRequest) (string, string) {
+	if req.ReceiveId == "" || req.MessageId == "" {
+		return enum.ErrInvalidMessage, "缺少会话或消息id"
+	}
+	var message model.Message
+	if res := dao.GormDB.First(&message, "uuid = ?", req.MessageId); res.Error != nil {
+		if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			zlog.Error(res.Error.Error())
+		}
+		return readError(res.Error)
+	}
+	// 消息必须属于该会话
+	if req.ReceiveId[0] == 'G' {
+		if message.ReceiveId != req.ReceiveId {
+			return enum.ErrInvalidMessage, "消息不属于该会话"
+		}
+	} else if !(message.SendId == req.ReceiveId && message.ReceiveId == c.Uuid) &&
+		!(message.SendId == c.Uuid && message.ReceiveId == req.ReceiveId) {
+		return enum.ErrInvalidMessage, "消息不属于该会话"
+	}
+
+	now := time.Now()
+	// 已读进度只前进不后退
+	res := dao.GormDB.Model(&model.Session{}).
+		Where("send_id = ? AND receive_id = ? AND last_read_id < ?", c.Uuid, req.ReceiveId, message.Id).
+		Updates(map[string]interface{}{
+			"last_read_id":   message.Id,
+			"last_read_uuid": message.Uuid,
+			"last_read_at":   sql.NullTime{Time: now, Valid: true},
+		})
+	if res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	if res.RowsAffected == 0 {
+		return "", ""
+	}
+
+	readEvent := respond.ReadEventRespond{
+		Event:     enum.EventRead,
+		ReaderId:  c.Uuid,
+		ReceiveId: req.ReceiveId,
+		MessageId: message.Uuid,
+		ReadAt:    now.Format("2006-01-02 15:04:05"),
+	}
+	jsonMessage, err := json.Marshal(readEvent)
+	if err != nil {
+		zlog.Error(err.Error())
+		return enum.ErrSystem, constants.SYSTEM_ERROR
+	}
+	messageBack := &MessageBack{Message: jsonMessage}
+	if req.ReceiveId[0] == 'U' {
+		if res := dao.GormDB.Model(&model.Message{}).
+			Where("send_id = ? AND receive_id = ? AND id <= ? AND status != ?", req.ReceiveId, c.Uuid, message.Id, enum.Read).
+			Update("status", enum.Read); res.Error != nil {
+			zlog.Error(res.Error.Error())
+		}
+		SendToUser(req.ReceiveId, messageBack)
+	}
+	// 自己的其他设备同步未读数
+	SendToUser(c.Uuid, messageBack)
+	zlog.Info(fmt.Sprintf("用户%s已读会话%s至消息%s", c.Uuid, req.ReceiveId, message.Uuid))
+	return "", ""
+}
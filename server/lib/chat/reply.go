@@ -0,0 +1,121 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	myredis "Kama-Chat/lib/redis"
+	"Kama-Chat/model"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"time"
+)
+
+// snippetLength 引用摘要的最大字符数
+const snippetLength = 50
+
+// QuoteOf 生成被引用消息的摘要，文本截取前若干个字符，其他类型用类型提示代替
+func QuoteOf(message model.Message) *respond.QuotedMessageRespond {
+	quote := &respond.QuotedMessageRespond{
+		Uuid:       message.Uuid,
+		SendId:     message.SendId,
+		SendName:   message.SendName,
+		Type:       message.Type,
+		IsRecalled: message.RecalledAt.Valid,
+	}
+	switch {
+	case message.RecalledAt.Valid:
+		quote.Snippet = constants.RECALLED_TEXT
+	case message.Type == enum.Text:
+		content := []rune(message.Content)
+		if len(content) > snippetLength {
+			quote.Snippet = string(content[:snippetLength]) + "..."
+		} else {
+			quote.Snippet = message.Content
+		}
+	case message.Type == enum.File:
+		quote.Snippet = "[文件] " + message.FileName
+	case message.Type == enum.Voice:
+		quote.Snippet = "[语音]"
+	default:
+		quote.Snippet = "[消息]"
+	}
+	return quote
+}
+
+// attachReply 校验引用的消息并填写消息的引用和话题，返回被引用消息的摘要
+// 只能引用同一会话中的消息，不满足时忽略引用，消息照常发送
+// 群聊中回复某条消息即加入以它为根的话题，回复话题中的消息时加入同一个话题
+func attachReply(message *model.Message, replyTo string) *respond.QuotedMessageRespond {
+	if replyTo == "" {
+		return nil
+	}
+	var quoted model.Message
+	if res := dao.GormDB.First(&quoted, "uuid = ?", replyTo); res.Error != nil {
+		if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			zlog.Error(res.Error.Error())
+		}
+		return nil
+	}
+	if message.ReceiveId[0] == 'G' {
+		if quoted.ReceiveId != message.ReceiveId {
+			return nil
+		}
+		message.ThreadId = quoted.Uuid
+		if quoted.ThreadId != "" {
+			message.ThreadId = quoted.ThreadId
+		}
+	} else if !(quoted.SendId == message.SendId && quoted.ReceiveId == message.ReceiveId) &&
+		!(quoted.SendId == message.ReceiveId && quoted.ReceiveId == message.SendId) {
+		return nil
+	}
+	message.ReplyTo = quoted.Uuid
+	return QuoteOf(quoted)
+}
+
+// countThreadReply 消息入库后增加话题根消息的回复数，并同步到群聊记录缓存
+func countThreadReply(message model.Message) {
+	if message.ThreadId == "" {
+		return
+	}
+	if res := dao.GormDB.Model(&model.Message{}).Where("uuid = ?", message.ThreadId).
+		Update("reply_count", gorm.Expr("reply_count + 1")); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	var root model.Message
+	if res := dao.GormDB.Select("uuid", "reply_count").First(&root, "uuid = ?", message.ThreadId); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	key := "group_messagelist_" + message.ReceiveId
+	rspString, err := myredis.GetKeyNilIsErr(key)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			zlog.Error(err.Error())
+		}
+		return
+	}
+	var rsp []respond.GetGroupMessageListRespond
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	for i := range rsp {
+		if rsp[i].Uuid == root.Uuid {
+			rsp[i].ReplyCount = root.ReplyCount
+		}
+	}
+	rspByte, err := json.Marshal(rsp)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if err := myredis.SetKeyEx(key, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
+		zlog.Error(err.Error())
+	}
+}
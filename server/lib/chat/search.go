@@ -0,0 +1,37 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/lib/search"
+	"Kama-Chat/model"
+	"Kama-Chat/utils/enum"
+)
+
+// MessageIndexer 消息搜索索引，未初始化时为nil，此时不建立索引
+var MessageIndexer search.Indexer
+
+// InitSearch 初始化消息搜索索引，需要在数据库初始化之后调用
+func InitSearch() {
+	MessageIndexer = search.NewMysqlIndexer(dao.GormDB)
+}
+
+// IndexMessage 消息入库或编辑后更新搜索索引，只索引文本消息
+func IndexMessage(message model.Message) {
+	if MessageIndexer == nil || message.Type != enum.Text {
+		return
+	}
+	if err := MessageIndexer.Index(message); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// RemoveMessageIndex 消息撤回后删除搜索索引
+func RemoveMessageIndex(messageUuid string) {
+	if MessageIndexer == nil {
+		return
+	}
+	if err := MessageIndexer.Remove(messageUuid); err != nil {
+		zlog.Error(err.Error())
+	}
+}
@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ConversationId 消息所在会话的id，群聊为群uuid，单聊为双方uuid按字典序拼接，双方得到的结果相同
+func ConversationId(sendId string, receiveId string) string {
+	if receiveId[0] == 'G' {
+		return receiveId
+	}
+	if sendId < receiveId {
+		return sendId + "_" + receiveId
+	}
+	return receiveId + "_" + sendId
+}
+
+// CreateMessage 为消息分配会话内的序号并入库
+// 序号在同一个事务里递增，递增时加的行锁持有到事务结束，消息写入失败时序号一起回滚，保证同一会话内的序号连续且不重复
+func CreateMessage(message *model.Message) error {
+	message.ConversationId = ConversationId(message.SendId, message.ReceiveId)
+	return dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// 会话的第一条消息插入序号1，之后在同一条语句里加一，避免先读后写时并发事务互相等待
+		seq := model.ConversationSeq{ConversationId: message.ConversationId, Seq: 1, UpdatedAt: now}
+		if res := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "conversation_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"seq": gorm.Expr("seq + 1"), "updated_at": now}),
+		}).Create(&seq); res.Error != nil {
+			return res.Error
+		}
+		var current model.ConversationSeq
+		if res := tx.Select("seq").First(&current, "conversation_id = ?", message.ConversationId); res.Error != nil {
+			return res.Error
+		}
+		message.Seq = current.Seq
+		return tx.Create(message).Error
+	})
+}
@@ -1,35 +1,28 @@
 package chat
 
 import (
-	"Kama-Chat/initialize/dao"
 	"Kama-Chat/initialize/zlog"
-	myredis "Kama-Chat/lib/redis"
-	"Kama-Chat/model"
-	"Kama-Chat/model/request"
-	"Kama-Chat/model/respond"
+	"Kama-Chat/lib/cluster"
 	"Kama-Chat/utils/constants"
 	"Kama-Chat/utils/enum"
-	"Kama-Chat/utils/random"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"github.com/go-redis/redis/v8"
-	"github.com/gorilla/websocket"
 	"log"
 	"strings"
 	"sync"
-	"time"
 )
 
 // Server 定义聊天服务器的结构体
-// 用于管理客户端连接、消息转发以及客户端登录/登出等操作
+// 用于管理客户端连接、处理客户端发来的消息以及客户端登录/登出等操作
+// 消息经过传输层后由同一套流程处理，通道模式和Kafka模式只是传输层不同
 type Server struct {
-	// Clients 存储所有在线客户端，以客户端 UUID 为键，*Client 对象为值
-	Clients map[string]*Client
+	// Clients 存储所有在线客户端，同一用户的多个设备连接各自独立保存
+	Clients ClientSet
 	// mutex 用于保护 Clients 映射的并发访问，确保线程安全
 	mutex *sync.Mutex
-	// Transmit 消息转发通道，用于将接收到的消息广播给所有在线客户端
-	Transmit chan []byte // 转发通道
+	// transport 消息传输层，客户端发来的消息经过它交给处理流程
+	transport Transport
+	// node 集群节点，未开启集群时为nil
+	node *cluster.Node
 	// Login 登录通道，接收新上线的客户端对象，用于添加到在线列表
 	Login chan *Client // 登录通道
 	// Logout 登出通道，接收下线的客户端对象，用于从在线列表中移除
@@ -39,26 +32,33 @@ type Server struct {
 // ChatServer 是 Server 的全局实例，表示当前运行的聊天服务器
 var ChatServer *Server
 
-// init函数用于初始化ChatServer实例。
-// 当ChatServer实例不存在时，通过创建一个新的Server实例并将其赋值给ChatServer。
-// 这个初始化过程包括：
-// - 创建一个空的Clients字典，用于后续存储客户端信息。
-// - 初始化一个互斥锁mutex，用于在并发环境下保护Clients字典的安全访问。
-// - 创建Transmit、Login和Logout通道，分别用于消息传输、客户端登录和注销的管理。
+// init函数用于初始化ChatServer实例，默认使用进程内通道作为传输层
+// Kafka模式在启动前通过 SetTransport 替换传输层
 func init() {
 	// 如果 ChatServer 尚未初始化，则创建一个新实例
 	if ChatServer == nil {
-		// 创建一个新实例
-		ChatServer = &Server{
-			Clients:  make(map[string]*Client),                   // 创建一个空的Clients字典
-			mutex:    &sync.Mutex{},                              // 创建一个互斥锁
-			Transmit: make(chan []byte, constants.CHANNEL_SIZE),  // 创建一个Transmit通道
-			Login:    make(chan *Client, constants.CHANNEL_SIZE), // 创建一个Login通道
-			Logout:   make(chan *Client, constants.CHANNEL_SIZE), // 创建一个Logout通道
-		}
+		ChatServer = NewServer(NewChannelTransport(constants.CHANNEL_SIZE), nil)
+	}
+}
+
+// NewServer 创建使用指定传输层的聊天服务器，node 为nil时不开启集群
+// node 需要把其他节点转发过来的消息交给 DeliverFromCluster
+func NewServer(transport Transport, node *cluster.Node) *Server {
+	return &Server{
+		Clients:   make(ClientSet),                            // 创建一个空的Clients字典
+		mutex:     &sync.Mutex{},                              // 创建一个互斥锁
+		transport: transport,                                  // 消息传输层
+		node:      node,                                       // 集群节点
+		Login:     make(chan *Client, constants.CHANNEL_SIZE), // 创建一个Login通道
+		Logout:    make(chan *Client, constants.CHANNEL_SIZE), // 创建一个Logout通道
 	}
 }
 
+// SetTransport 替换传输层，只能在 Start 之前调用
+func (s *Server) SetTransport(transport Transport) {
+	s.transport = transport
+}
+
 // 将https://127.0.0.1:8000/static/xxx 转为 /static/xxx
 func normalizePath(path string) string {
 	// 查找 "/static/" 的位置
@@ -69,455 +69,146 @@ func normalizePath(path string) string {
 	if staticIndex < 0 {
 		log.Println(path)
 		zlog.Error("路径不合法")
+		return path
 	}
 	// 返回从 "/static/" 开始的部分
 	return path[staticIndex:]
 }
 
 // Start 启动函数，Server端用主进程起，Client端可以用协程起
+// 消息在单独的协程中按传输层给出的顺序依次处理，登录登出在当前协程处理
 func (s *Server) Start() {
 	defer func() {
-		close(s.Transmit)
 		close(s.Logout)
 		close(s.Login)
 	}()
+	go s.transport.Consume(s.process)
 	for {
 		select {
 		case client := <-s.Login:
 			{
-				s.mutex.Lock()
-				s.Clients[client.Uuid] = client
-				s.mutex.Unlock()
-				zlog.Debug(fmt.Sprintf("欢迎来到kama聊天服务器，亲爱的用户%s\n", client.Uuid))
-				err := client.Conn.WriteMessage(websocket.TextMessage, []byte("欢迎来到kama聊天服务器"))
-				if err != nil {
-					zlog.Error(err.Error())
+				firstDevice := s.AddClient(client)
+				zlog.Debug(fmt.Sprintf("欢迎来到kama聊天服务器，亲爱的用户%s，设备%s\n", client.Uuid, client.DeviceId))
+				if firstDevice {
+					userOnline(client.Uuid)
 				}
 			}
 
 		case client := <-s.Logout:
 			{
 				s.mutex.Lock()
-				delete(s.Clients, client.Uuid)
-				s.mutex.Unlock()
-				zlog.Info(fmt.Sprintf("用户%s退出登录\n", client.Uuid))
-				if err := client.Conn.WriteMessage(websocket.TextMessage, []byte("已退出登录")); err != nil {
-					zlog.Error(err.Error())
-				}
-			}
-
-		case data := <-s.Transmit:
-			{
-				var chatMessageReq request.ChatMessageRequest
-				if err := json.Unmarshal(data, &chatMessageReq); err != nil {
-					zlog.Error(err.Error())
-				}
-				// log.Println("原消息为：", data, "反序列化后为：", chatMessageReq)
-				if chatMessageReq.Type == enum.Text {
-					// 存message
-					message := model.Message{
-						Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
-						SessionId:  chatMessageReq.SessionId,
-						Type:       chatMessageReq.Type,
-						Content:    chatMessageReq.Content,
-						Url:        "",
-						SendId:     chatMessageReq.SendId,
-						SendName:   chatMessageReq.SendName,
-						SendAvatar: chatMessageReq.SendAvatar,
-						ReceiveId:  chatMessageReq.ReceiveId,
-						FileSize:   "0B",
-						FileType:   "",
-						FileName:   "",
-						Status:     enum.Unsent,
-						CreatedAt:  time.Now(),
-						AVdata:     "",
-					}
-					// 对SendAvatar去除前面/static之前的所有内容，防止ip前缀引入
-					message.SendAvatar = normalizePath(message.SendAvatar)
-					if res := dao.GormDB.Create(&message); res.Error != nil {
-						zlog.Error(res.Error.Error())
-					}
-					if message.ReceiveId[0] == 'U' { // 发送给User
-						// 如果能找到ReceiveId，说明在线，可以发送，否则存表后跳过
-						// 因为在线的时候是通过websocket更新消息记录的，离线后通过存表，登录时只调用一次数据库操作
-						// 切换chat对象后，前端的messageList也会改变，获取messageList从第二次就是从redis中获取
-						messageRsp := respond.GetMessageListRespond{
-							SendId:     message.SendId,
-							SendName:   message.SendName,
-							SendAvatar: chatMessageReq.SendAvatar,
-							ReceiveId:  message.ReceiveId,
-							Type:       message.Type,
-							Content:    message.Content,
-							Url:        message.Url,
-							FileSize:   message.FileSize,
-							FileName:   message.FileName,
-							FileType:   message.FileType,
-							CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
-						}
-						jsonMessage, err := json.Marshal(messageRsp)
-						if err != nil {
-							zlog.Error(err.Error())
-						}
-						log.Println("返回的消息为：", messageRsp, "序列化后为：", jsonMessage)
-						var messageBack = &MessageBack{
-							Message: jsonMessage,
-							Uuid:    message.Uuid,
-						}
-						s.mutex.Lock()
-						if receiveClient, ok := s.Clients[message.ReceiveId]; ok {
-							//messageBack.Message = jsonMessage
-							//messageBack.Uuid = message.Uuid
-							receiveClient.SendBack <- messageBack // 向client.Send发送
-						}
-						// 因为send_id肯定在线，所以这里在后端进行在线回显message，其实优化的话前端可以直接回显
-						// 问题在于前后端的req和rsp结构不同，前端存储message的messageList不能存req，只能存rsp
-						// 所以这里后端进行回显，前端不回显
-						sendClient := s.Clients[message.SendId]
-						sendClient.SendBack <- messageBack
-						s.mutex.Unlock()
-
-						// redis
-						var rspString string
-						rspString, err = myredis.GetKeyNilIsErr("message_list_" + message.SendId + "_" + message.ReceiveId)
-						if err == nil {
-							var rsp []respond.GetMessageListRespond
-							if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
-								zlog.Error(err.Error())
-							}
-							rsp = append(rsp, messageRsp)
-							rspByte, err := json.Marshal(rsp)
-							if err != nil {
-								zlog.Error(err.Error())
-							}
-							if err := myredis.SetKeyEx("message_list_"+message.SendId+"_"+message.ReceiveId, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
-								zlog.Error(err.Error())
-							}
-						} else {
-							if !errors.Is(err, redis.Nil) {
-								zlog.Error(err.Error())
-							}
-						}
-
-					} else if message.ReceiveId[0] == 'G' { // 发送给Group
-						messageRsp := respond.GetGroupMessageListRespond{
-							SendId:     message.SendId,
-							SendName:   message.SendName,
-							SendAvatar: chatMessageReq.SendAvatar,
-							ReceiveId:  message.ReceiveId,
-							Type:       message.Type,
-							Content:    message.Content,
-							Url:        message.Url,
-							FileSize:   message.FileSize,
-							FileName:   message.FileName,
-							FileType:   message.FileType,
-							CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
-						}
-						jsonMessage, err := json.Marshal(messageRsp)
-						if err != nil {
-							zlog.Error(err.Error())
-						}
-						log.Println("返回的消息为：", messageRsp, "序列化后为：", jsonMessage)
-						var messageBack = &MessageBack{
-							Message: jsonMessage,
-							Uuid:    message.Uuid,
-						}
-						var group model.GroupInfo
-						if res := dao.GormDB.Where("uuid = ?", message.ReceiveId).First(&group); res.Error != nil {
-							zlog.Error(res.Error.Error())
-						}
-						var members []string
-						if err := json.Unmarshal(group.Members, &members); err != nil {
-							zlog.Error(err.Error())
-						}
-						s.mutex.Lock()
-						for _, member := range members {
-							if member != message.SendId {
-								if receiveClient, ok := s.Clients[member]; ok {
-									receiveClient.SendBack <- messageBack
-								}
-							} else {
-								sendClient := s.Clients[message.SendId]
-								sendClient.SendBack <- messageBack
-							}
-						}
-						s.mutex.Unlock()
-
-						// redis
-						var rspString string
-						rspString, err = myredis.GetKeyNilIsErr("group_messagelist_" + message.ReceiveId)
-						if err == nil {
-							var rsp []respond.GetGroupMessageListRespond
-							if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
-								zlog.Error(err.Error())
-							}
-							rsp = append(rsp, messageRsp)
-							rspByte, err := json.Marshal(rsp)
-							if err != nil {
-								zlog.Error(err.Error())
-							}
-							if err := myredis.SetKeyEx("group_messagelist_"+message.ReceiveId, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
-								zlog.Error(err.Error())
-							}
-						} else {
-							if !errors.Is(err, redis.Nil) {
-								zlog.Error(err.Error())
-							}
-						}
-					}
-				} else if chatMessageReq.Type == enum.File {
-					// 存message
-					message := model.Message{
-						Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
-						SessionId:  chatMessageReq.SessionId,
-						Type:       chatMessageReq.Type,
-						Content:    "",
-						Url:        chatMessageReq.Url,
-						SendId:     chatMessageReq.SendId,
-						SendName:   chatMessageReq.SendName,
-						SendAvatar: chatMessageReq.SendAvatar,
-						ReceiveId:  chatMessageReq.ReceiveId,
-						FileSize:   chatMessageReq.FileSize,
-						FileType:   chatMessageReq.FileType,
-						FileName:   chatMessageReq.FileName,
-						Status:     enum.Unsent,
-						CreatedAt:  time.Now(),
-						AVdata:     "",
-					}
-					// 对SendAvatar去除前面/static之前的所有内容，防止ip前缀引入
-					message.SendAvatar = normalizePath(message.SendAvatar)
-					if res := dao.GormDB.Create(&message); res.Error != nil {
-						zlog.Error(res.Error.Error())
-					}
-					if message.ReceiveId[0] == 'U' { // 发送给User
-						// 如果能找到ReceiveId，说明在线，可以发送，否则存表后跳过
-						// 因为在线的时候是通过websocket更新消息记录的，离线后通过存表，登录时只调用一次数据库操作
-						// 切换chat对象后，前端的messageList也会改变，获取messageList从第二次就是从redis中获取
-						messageRsp := respond.GetMessageListRespond{
-							SendId:     message.SendId,
-							SendName:   message.SendName,
-							SendAvatar: chatMessageReq.SendAvatar,
-							ReceiveId:  message.ReceiveId,
-							Type:       message.Type,
-							Content:    message.Content,
-							Url:        message.Url,
-							FileSize:   message.FileSize,
-							FileName:   message.FileName,
-							FileType:   message.FileType,
-							CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
-						}
-						jsonMessage, err := json.Marshal(messageRsp)
-						if err != nil {
-							zlog.Error(err.Error())
-						}
-						log.Println("返回的消息为：", messageRsp, "序列化后为：", jsonMessage)
-						var messageBack = &MessageBack{
-							Message: jsonMessage,
-							Uuid:    message.Uuid,
-						}
-						s.mutex.Lock()
-						if receiveClient, ok := s.Clients[message.ReceiveId]; ok {
-							//messageBack.Message = jsonMessage
-							//messageBack.Uuid = message.Uuid
-							receiveClient.SendBack <- messageBack // 向client.Send发送
-						}
-						// 因为send_id肯定在线，所以这里在后端进行在线回显message，其实优化的话前端可以直接回显
-						// 问题在于前后端的req和rsp结构不同，前端存储message的messageList不能存req，只能存rsp
-						// 所以这里后端进行回显，前端不回显
-						sendClient := s.Clients[message.SendId]
-						sendClient.SendBack <- messageBack
-						s.mutex.Unlock()
-
-						// redis
-						var rspString string
-						rspString, err = myredis.GetKeyNilIsErr("message_list_" + message.SendId + "_" + message.ReceiveId)
-						if err == nil {
-							var rsp []respond.GetMessageListRespond
-							if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
-								zlog.Error(err.Error())
-							}
-							rsp = append(rsp, messageRsp)
-							rspByte, err := json.Marshal(rsp)
-							if err != nil {
-								zlog.Error(err.Error())
-							}
-							if err := myredis.SetKeyEx("message_list_"+message.SendId+"_"+message.ReceiveId, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
-								zlog.Error(err.Error())
-							}
-						} else {
-							if !errors.Is(err, redis.Nil) {
-								zlog.Error(err.Error())
-							}
-						}
-					} else {
-						messageRsp := respond.GetGroupMessageListRespond{
-							SendId:     message.SendId,
-							SendName:   message.SendName,
-							SendAvatar: chatMessageReq.SendAvatar,
-							ReceiveId:  message.ReceiveId,
-							Type:       message.Type,
-							Content:    message.Content,
-							Url:        message.Url,
-							FileSize:   message.FileSize,
-							FileName:   message.FileName,
-							FileType:   message.FileType,
-							CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
-						}
-						jsonMessage, err := json.Marshal(messageRsp)
-						if err != nil {
-							zlog.Error(err.Error())
-						}
-						log.Println("返回的消息为：", messageRsp, "序列化后为：", jsonMessage)
-						var messageBack = &MessageBack{
-							Message: jsonMessage,
-							Uuid:    message.Uuid,
-						}
-						var group model.GroupInfo
-						if res := dao.GormDB.Where("uuid = ?", message.ReceiveId).First(&group); res.Error != nil {
-							zlog.Error(res.Error.Error())
-						}
-						var members []string
-						if err := json.Unmarshal(group.Members, &members); err != nil {
-							zlog.Error(err.Error())
-						}
-						s.mutex.Lock()
-						for _, member := range members {
-							if member != message.SendId {
-								if receiveClient, ok := s.Clients[member]; ok {
-									receiveClient.SendBack <- messageBack
-								}
-							} else {
-								sendClient := s.Clients[message.SendId]
-								sendClient.SendBack <- messageBack
-							}
-						}
-						s.mutex.Unlock()
-
-						// redis
-						var rspString string
-						rspString, err = myredis.GetKeyNilIsErr("group_messagelist_" + message.ReceiveId)
-						if err == nil {
-							var rsp []respond.GetGroupMessageListRespond
-							if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
-								zlog.Error(err.Error())
-							}
-							rsp = append(rsp, messageRsp)
-							rspByte, err := json.Marshal(rsp)
-							if err != nil {
-								zlog.Error(err.Error())
-							}
-							if err := myredis.SetKeyEx("group_messagelist_"+message.ReceiveId, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
-								zlog.Error(err.Error())
-							}
-						} else {
-							if !errors.Is(err, redis.Nil) {
-								zlog.Error(err.Error())
-							}
-						}
-					}
-				} else if chatMessageReq.Type == enum.AudioOrVideo {
-					var avData request.AVData
-					if err := json.Unmarshal([]byte(chatMessageReq.AVdata), &avData); err != nil {
-						zlog.Error(err.Error())
-					}
-					//log.Println(avData)
-					message := model.Message{
-						Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
-						SessionId:  chatMessageReq.SessionId,
-						Type:       chatMessageReq.Type,
-						Content:    "",
-						Url:        "",
-						SendId:     chatMessageReq.SendId,
-						SendName:   chatMessageReq.SendName,
-						SendAvatar: chatMessageReq.SendAvatar,
-						ReceiveId:  chatMessageReq.ReceiveId,
-						FileSize:   "",
-						FileType:   "",
-						FileName:   "",
-						Status:     enum.Unsent,
-						CreatedAt:  time.Now(),
-						AVdata:     chatMessageReq.AVdata,
-					}
-					if avData.MessageId == "PROXY" && (avData.Type == "start_call" || avData.Type == "receive_call" || avData.Type == "reject_call") {
-						// 存message
-						// 对SendAvatar去除前面/static之前的所有内容，防止ip前缀引入
-						message.SendAvatar = normalizePath(message.SendAvatar)
-						if res := dao.GormDB.Create(&message); res.Error != nil {
-							zlog.Error(res.Error.Error())
+				lastDevice := false
+				// 只关闭请求登出的那个设备，已经被移除的连接不重复处理
+				if s.Clients.Remove(client) {
+					s.clusterOffline(client)
+					zlog.Info(fmt.Sprintf("用户%s设备%s退出登录\n", client.Uuid, client.DeviceId))
+					if messageBack := noticeEvent(enum.EventLogout, client, "已退出登录"); messageBack != nil {
+						select {
+						case client.SendBack <- messageBack:
+						default:
 						}
 					}
-
-					if chatMessageReq.ReceiveId[0] == 'U' { // 发送给User
-						// 如果能找到ReceiveId，说明在线，可以发送，否则存表后跳过
-						// 因为在线的时候是通过websocket更新消息记录的，离线后通过存表，登录时只调用一次数据库操作
-						// 切换chat对象后，前端的messageList也会改变，获取messageList从第二次就是从redis中获取
-						messageRsp := respond.AVMessageRespond{
-							SendId:     message.SendId,
-							SendName:   message.SendName,
-							SendAvatar: message.SendAvatar,
-							ReceiveId:  message.ReceiveId,
-							Type:       message.Type,
-							Content:    message.Content,
-							Url:        message.Url,
-							FileSize:   message.FileSize,
-							FileName:   message.FileName,
-							FileType:   message.FileType,
-							CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
-							AVdata:     message.AVdata,
-						}
-						jsonMessage, err := json.Marshal(messageRsp)
-						if err != nil {
-							zlog.Error(err.Error())
-						}
-						// log.Println("返回的消息为：", messageRsp, "序列化后为：", jsonMessage)
-						log.Println("返回的消息为：", messageRsp)
-						var messageBack = &MessageBack{
-							Message: jsonMessage,
-							Uuid:    message.Uuid,
-						}
-						s.mutex.Lock()
-						if receiveClient, ok := s.Clients[message.ReceiveId]; ok {
-							//messageBack.Message = jsonMessage
-							//messageBack.Uuid = message.Uuid
-							receiveClient.SendBack <- messageBack // 向client.Send发送
-						}
-						// 通话这不能回显，发回去的话就会出现两个start_call。
-						//sendClient := s.Clients[message.SendId]
-						//sendClient.SendBack <- messageBack
-						s.mutex.Unlock()
-					}
+					client.close()
+					// 最后一个设备下线时用户才算离线
+					lastDevice = !s.Clients.Online(client.Uuid) && !s.onlineElsewhere(client.Uuid)
+				}
+				s.mutex.Unlock()
+				if lastDevice {
+					userOffline(client.Uuid)
 				}
-
 			}
 		}
 	}
 }
 
+// Close 关闭传输层，不再处理新的消息
 func (s *Server) Close() {
-	close(s.Login)
-	close(s.Logout)
-	close(s.Transmit)
+	if err := s.transport.Close(); err != nil {
+		zlog.Error(err.Error())
+	}
 }
 
 func (s *Server) SendClientToLogin(client *Client) {
-	s.mutex.Lock()
 	s.Login <- client
-	s.mutex.Unlock()
 }
 
 func (s *Server) SendClientToLogout(client *Client) {
-	s.mutex.Lock()
 	s.Logout <- client
-	s.mutex.Unlock()
 }
 
-func (s *Server) SendMessageToTransmit(message []byte) {
+// Publish 把客户端发来的消息交给传输层
+func (s *Server) Publish(message []byte) error {
+	return s.transport.Publish(message)
+}
+
+// AddClient 登记设备连接，同一设备重复连接时顶替旧连接
+// 返回用户此前是否在整个集群中都没有设备在线，上线通知由调用方在锁外完成
+func (s *Server) AddClient(client *Client) bool {
 	s.mutex.Lock()
-	s.Transmit <- message
-	s.mutex.Unlock()
+	defer s.mutex.Unlock()
+	// 用户此前没有任何设备在线时，这次登录就是上线
+	firstDevice := !s.Clients.Online(client.Uuid)
+	if old := s.Clients.Add(client); old != nil {
+		old.close()
+	}
+	firstDevice = firstDevice && !s.onlineElsewhere(client.Uuid)
+	s.clusterOnline(client)
+	// 欢迎消息也经过发送通道，保证只有写协程在写连接
+	if messageBack := noticeEvent(enum.EventLogin, client, "欢迎来到kama聊天服务器"); messageBack != nil {
+		s.Clients.SendToDevice(client, messageBack)
+	}
+	return firstDevice
 }
 
+// RemoveClient 移除用户的所有设备连接
 func (s *Server) RemoveClient(uuid string) {
 	s.mutex.Lock()
-	delete(s.Clients, uuid)
+	for _, client := range s.Clients.Devices(uuid) {
+		s.Clients.Remove(client)
+		s.clusterOffline(client)
+		client.close()
+	}
+	s.mutex.Unlock()
+}
+
+// GetClient 获取用户在某个设备上的连接
+func (s *Server) GetClient(uuid string, deviceId string) *Client {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Clients.Get(uuid, deviceId)
+}
+
+// SendToUser 把消息投递到用户的所有设备，开启集群时包括其他节点上的设备
+func (s *Server) SendToUser(uuid string, messageBack *MessageBack) {
+	s.deliver(messageBack, uuid)
+}
+
+// SendToUsers 把消息投递给多个用户的所有设备，开启集群时一次转发给持有他们连接的节点
+func (s *Server) SendToUsers(messageBack *MessageBack, uuids ...string) {
+	s.deliver(messageBack, uuids...)
+}
+
+// SendToClient 把消息投递到某一个设备，该设备已下线时丢弃
+func (s *Server) SendToClient(client *Client, messageBack *MessageBack) {
+	s.mutex.Lock()
+	s.Clients.SendToDevice(client, messageBack)
 	s.mutex.Unlock()
 }
+
+// IsOnline 判断用户是否在本实例上有设备在线
+func (s *Server) IsOnline(uuid string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Clients.Online(uuid)
+}
+
+// AllClients 获取本实例上的所有连接
+func (s *Server) AllClients() []*Client {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var clients []*Client
+	for uuid := range s.Clients {
+		clients = append(clients, s.Clients.Devices(uuid)...)
+	}
+	return clients
+}
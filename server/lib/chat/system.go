@@ -0,0 +1,36 @@
+package chat
+
+import (
+	"Kama-Chat/model"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/random"
+	"fmt"
+	"time"
+)
+
+// systemSendName 系统通知的发送者昵称，发送者uuid为群聊uuid
+const systemSendName = "系统消息"
+
+// SendGroupSystemMessage 在群里发出一条系统通知，和普通消息一样入库、分配序号并推送给全体群成员
+func SendGroupSystemMessage(groupId string, content string) error {
+	message := model.Message{
+		Uuid:      fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
+		Type:      enum.System,
+		Content:   content,
+		FileSize:  "0B",
+		SendId:    groupId,
+		SendName:  systemSendName,
+		ReceiveId: groupId,
+		Status:    enum.Unsent,
+		CreatedAt: time.Now(),
+	}
+	if err := CreateMessage(&message); err != nil {
+		return err
+	}
+	messageRsp := GroupRespond(message, nil)
+	if messageBack := marshalMessageBack(message, messageRsp); messageBack != nil {
+		ChatServer.route(message, messageBack)
+	}
+	appendCachedGroupMessage(groupId, messageRsp)
+	return nil
+}
@@ -0,0 +1,119 @@
+package chat
+
+import (
+	"Kama-Chat/global"
+	"Kama-Chat/initialize/zlog"
+	myKafka "Kama-Chat/lib/kafka"
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"strconv"
+	"sync"
+)
+
+var ctx = context.Background()
+
+// ErrTransportFull 传输层的缓冲已满，客户端需要稍后重发
+var ErrTransportFull = errors.New("由于目前同一时间过多用户发送消息，消息发送失败，请稍后重试")
+
+// ErrTransportClosed 传输层已关闭
+var ErrTransportClosed = errors.New("消息通道已关闭")
+
+// Transport 聊天消息的传输层
+// 读协程把客户端发来的消息交给传输层，server 从传输层依次取出并处理，处理流程与传输方式无关
+type Transport interface {
+	// Publish 投递一条客户端发来的消息
+	Publish(data []byte) error
+	// Consume 阻塞读取消息并依次交给 handle 处理，传输层关闭后返回
+	Consume(handle func(data []byte))
+	// Close 关闭传输层
+	Close() error
+}
+
+// ChannelTransport 基于进程内通道的传输层，用于单实例部署
+type ChannelTransport struct {
+	messages  chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewChannelTransport 创建缓冲为 size 条消息的通道传输层
+func NewChannelTransport(size int) *ChannelTransport {
+	return &ChannelTransport{
+		messages: make(chan []byte, size),
+		done:     make(chan struct{}),
+	}
+}
+
+// Publish 缓冲已满时不阻塞读协程，直接返回 ErrTransportFull
+func (t *ChannelTransport) Publish(data []byte) error {
+	select {
+	case <-t.done:
+		return ErrTransportClosed
+	default:
+	}
+	select {
+	case t.messages <- data:
+		return nil
+	default:
+		return ErrTransportFull
+	}
+}
+
+func (t *ChannelTransport) Consume(handle func(data []byte)) {
+	for {
+		select {
+		case data := <-t.messages:
+			handle(data)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *ChannelTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+	return nil
+}
+
+// KafkaTransport 基于Kafka的传输层，消息写入聊天topic后由消费者读取
+// 所有消息使用同一个key写入同一个分区，保证处理顺序与发送顺序一致
+type KafkaTransport struct {
+	key []byte
+}
+
+// NewKafkaTransport 创建Kafka传输层，需要在 kafka.KafkaService.KafkaInit 之后调用
+func NewKafkaTransport() *KafkaTransport {
+	return &KafkaTransport{key: []byte(strconv.Itoa(global.CONFIG.KafkaConfig.Partition))}
+}
+
+func (t *KafkaTransport) Publish(data []byte) error {
+	return myKafka.KafkaService.ChatWriter.WriteMessages(ctx, kafka.Message{
+		Key:   t.key,
+		Value: data,
+	})
+}
+
+func (t *KafkaTransport) Consume(handle func(data []byte)) {
+	for {
+		kafkaMessage, err := myKafka.KafkaService.ChatReader.ReadMessage(ctx)
+		if err != nil {
+			// 读取器关闭后返回io.EOF
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			zlog.Error(err.Error())
+			continue
+		}
+		handle(kafkaMessage.Value)
+	}
+}
+
+// Close 关闭Kafka的读写器
+func (t *KafkaTransport) Close() error {
+	myKafka.KafkaService.KafkaClose()
+	return nil
+}
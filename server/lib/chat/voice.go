@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/utils/constants"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ValidateVoice 校验语音的时长和波形，上传语音和发送语音消息时都会校验
+func ValidateVoice(duration int, waveform []int) error {
+	if duration <= 0 || duration > constants.VOICE_MAX_DURATION {
+		return fmt.Errorf("语音时长需要在1到%d秒之间", constants.VOICE_MAX_DURATION)
+	}
+	if len(waveform) == 0 || len(waveform) > constants.VOICE_WAVEFORM_SIZE {
+		return fmt.Errorf("语音波形需要有1到%d个采样点", constants.VOICE_WAVEFORM_SIZE)
+	}
+	for _, sample := range waveform {
+		if sample < 0 || sample > 100 {
+			return errors.New("语音波形的采样点需要在0到100之间")
+		}
+	}
+	return nil
+}
+
+// attachVoice 校验语音消息并把时长和波形写入消息
+func attachVoice(message *model.Message, req request.ChatMessageRequest) error {
+	if req.Url == "" {
+		return errors.New("语音消息缺少语音地址")
+	}
+	if err := ValidateVoice(req.Duration, req.Waveform); err != nil {
+		return err
+	}
+	data, err := json.Marshal(req.Waveform)
+	if err != nil {
+		return err
+	}
+	message.Duration = req.Duration
+	message.Waveform = string(data)
+	return nil
+}
+
+// WaveformOf 解析语音消息的波形，不是语音消息时返回nil
+func WaveformOf(message model.Message) []int {
+	if message.Waveform == "" {
+		return nil
+	}
+	var waveform []int
+	if err := json.Unmarshal([]byte(message.Waveform), &waveform); err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return waveform
+}
@@ -0,0 +1,146 @@
+package cluster
+
+import (
+	"errors"
+)
+
+// ErrNodeUnreachable 目标节点没有订阅者，通常是节点已经下线
+var ErrNodeUnreachable = errors.New("节点不可达")
+
+// Envelope 节点之间转发的消息
+type Envelope struct {
+	// FromNode 转发消息的节点id
+	FromNode string `json:"from_node"`
+	// UserIds 接收消息的用户uuid，由目标节点投递到这些用户在本节点上的所有设备
+	UserIds []string `json:"user_ids"`
+	// MessageUuid 消息uuid
+	MessageUuid string `json:"message_uuid"`
+	// Message 发给前端的消息内容
+	Message []byte `json:"message"`
+	// NeedAck 是否需要客户端确认
+	NeedAck bool `json:"need_ack"`
+}
+
+// Presence 在线状态注册表，记录每个用户的每个设备连接在哪个节点上
+type Presence interface {
+	// Online 登记设备连接所在节点
+	Online(userId string, deviceId string, nodeId string) error
+	// Offline 注销设备连接，只有登记的仍是该节点时才会注销，避免把重连到其他节点的登记删掉
+	Offline(userId string, deviceId string, nodeId string) error
+	// Nodes 批量获取持有这些用户连接的节点，以用户uuid为键，同一用户的节点已去重，不在线的用户没有对应的键
+	Nodes(userIds ...string) (map[string][]string, error)
+	// RemoveNode 删除该用户在某个节点上的全部登记，用于清理已下线节点的残留数据
+	RemoveNode(userId string, nodeId string) error
+}
+
+// Bus 节点之间的消息总线，每个节点只订阅发给自己的消息
+type Bus interface {
+	// Publish 把消息发给指定节点，目标节点没有订阅时返回 ErrNodeUnreachable
+	Publish(nodeId string, envelope *Envelope) error
+	// Subscribe 订阅发给指定节点的消息，返回取消订阅函数
+	Subscribe(nodeId string, handler func(envelope *Envelope)) (func(), error)
+}
+
+// Node 当前实例在集群中的节点
+// 本节点上的连接由聊天服务器直接投递，Node 只负责登记在线状态以及把消息转发给持有其他连接的节点
+type Node struct {
+	Id          string
+	presence    Presence
+	bus         Bus
+	unsubscribe func()
+}
+
+// NewNode 创建节点并开始接收其他节点转发过来的消息
+// deliverLocal 把消息投递到本节点上该用户的所有设备
+func NewNode(id string, presence Presence, bus Bus, deliverLocal func(envelope *Envelope)) (*Node, error) {
+	unsubscribe, err := bus.Subscribe(id, deliverLocal)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{
+		Id:          id,
+		presence:    presence,
+		bus:         bus,
+		unsubscribe: unsubscribe,
+	}, nil
+}
+
+// Online 登记本节点上的设备连接
+func (n *Node) Online(userId string, deviceId string) error {
+	return n.presence.Online(userId, deviceId, n.Id)
+}
+
+// Offline 注销本节点上的设备连接
+func (n *Node) Offline(userId string, deviceId string) error {
+	return n.presence.Offline(userId, deviceId, n.Id)
+}
+
+// OnlineElsewhere 判断用户是否在其他节点上有连接
+func (n *Node) OnlineElsewhere(userId string) (bool, error) {
+	nodes, err := n.presence.Nodes(userId)
+	if err != nil {
+		return false, err
+	}
+	for _, nodeId := range nodes[userId] {
+		if nodeId != n.Id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Forward 把发给 envelope.UserIds 的消息转发给持有这些用户连接的其他节点，本节点自身不在转发范围内
+// 在线状态一次批量获取，每个节点只发布一次，只带上在该节点上有连接的用户
+// 返回第一个遇到的错误，但会尽量转发给所有节点；已下线节点的登记会被顺带清理
+func (n *Node) Forward(envelope *Envelope) error {
+	if len(envelope.UserIds) == 0 {
+		return nil
+	}
+	userNodes, err := n.presence.Nodes(envelope.UserIds...)
+	if err != nil {
+		return err
+	}
+	// 按节点归并接收者，保持用户的先后顺序
+	var nodeIds []string
+	receivers := make(map[string][]string)
+	for _, userId := range envelope.UserIds {
+		for _, nodeId := range userNodes[userId] {
+			if nodeId == n.Id {
+				continue
+			}
+			if _, ok := receivers[nodeId]; !ok {
+				nodeIds = append(nodeIds, nodeId)
+			}
+			receivers[nodeId] = append(receivers[nodeId], userId)
+		}
+	}
+	var firstErr error
+	for _, nodeId := range nodeIds {
+		err := n.bus.Publish(nodeId, &Envelope{
+			FromNode:    n.Id,
+			UserIds:     receivers[nodeId],
+			MessageUuid: envelope.MessageUuid,
+			Message:     envelope.Message,
+			NeedAck:     envelope.NeedAck,
+		})
+		if errors.Is(err, ErrNodeUnreachable) {
+			err = nil
+			for _, userId := range receivers[nodeId] {
+				if removeErr := n.presence.RemoveNode(userId, nodeId); removeErr != nil && err == nil {
+					err = removeErr
+				}
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close 停止接收其他节点转发的消息
+func (n *Node) Close() {
+	if n.unsubscribe != nil {
+		n.unsubscribe()
+	}
+}
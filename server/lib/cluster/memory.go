@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"sync"
+)
+
+// MemoryPresence 基于内存的在线状态注册表，同一进程内的多个节点共享一个实例，用于测试或单机调试
+type MemoryPresence struct {
+	mutex sync.Mutex
+	// users 用户uuid -> 设备id -> 节点id
+	users map[string]map[string]string
+}
+
+// NewMemoryPresence 创建内存在线状态注册表
+func NewMemoryPresence() *MemoryPresence {
+	return &MemoryPresence{users: make(map[string]map[string]string)}
+}
+
+func (p *MemoryPresence) Online(userId string, deviceId string, nodeId string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	devices, ok := p.users[userId]
+	if !ok {
+		devices = make(map[string]string)
+		p.users[userId] = devices
+	}
+	devices[deviceId] = nodeId
+	return nil
+}
+
+func (p *MemoryPresence) Offline(userId string, deviceId string, nodeId string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.users[userId][deviceId] == nodeId {
+		delete(p.users[userId], deviceId)
+	}
+	if len(p.users[userId]) == 0 {
+		delete(p.users, userId)
+	}
+	return nil
+}
+
+func (p *MemoryPresence) Nodes(userIds ...string) (map[string][]string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	nodes := make(map[string][]string)
+	for _, userId := range userIds {
+		if devices := p.users[userId]; len(devices) > 0 {
+			nodes[userId] = distinctNodes(devices)
+		}
+	}
+	return nodes, nil
+}
+
+func (p *MemoryPresence) RemoveNode(userId string, nodeId string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for deviceId, id := range p.users[userId] {
+		if id == nodeId {
+			delete(p.users[userId], deviceId)
+		}
+	}
+	if len(p.users[userId]) == 0 {
+		delete(p.users, userId)
+	}
+	return nil
+}
+
+// MemoryBus 基于内存的消息总线，消息在发布方的goroutine中同步投递
+type MemoryBus struct {
+	mutex    sync.RWMutex
+	handlers map[string]func(envelope *Envelope)
+}
+
+// NewMemoryBus 创建内存消息总线
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{handlers: make(map[string]func(envelope *Envelope))}
+}
+
+func (b *MemoryBus) Publish(nodeId string, envelope *Envelope) error {
+	b.mutex.RLock()
+	handler, ok := b.handlers[nodeId]
+	b.mutex.RUnlock()
+	if !ok {
+		return ErrNodeUnreachable
+	}
+	handler(envelope)
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(nodeId string, handler func(envelope *Envelope)) (func(), error) {
+	b.mutex.Lock()
+	b.handlers[nodeId] = handler
+	b.mutex.Unlock()
+	return func() {
+		b.mutex.Lock()
+		delete(b.handlers, nodeId)
+		b.mutex.Unlock()
+	}, nil
+}
+
+// distinctNodes 从设备登记中取出去重后的节点id
+func distinctNodes(devices map[string]string) []string {
+	seen := make(map[string]bool, len(devices))
+	nodes := make([]string, 0, len(devices))
+	for _, nodeId := range devices {
+		if !seen[nodeId] {
+			seen[nodeId] = true
+			nodes = append(nodes, nodeId)
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+)
+
+// presenceKeyPrefix 在线状态hash的键前缀，完整键为 presence_<用户uuid>，field为设备id，value为节点id
+const presenceKeyPrefix = "presence_"
+
+// nodeChannelPrefix 节点订阅的频道前缀，完整频道为 cluster_node_<节点id>
+const nodeChannelPrefix = "cluster_node_"
+
+// offlineScript 只有设备登记的仍是当前节点时才删除，保证检查和删除是原子的
+var offlineScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call("HDEL", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// removeNodeScript 删除用户在某个节点上的全部设备登记
+var removeNodeScript = redis.NewScript(`
+local all = redis.call("HGETALL", KEYS[1])
+local removed = 0
+for i = 1, #all, 2 do
+	if all[i + 1] == ARGV[1] then
+		redis.call("HDEL", KEYS[1], all[i])
+		removed = removed + 1
+	end
+end
+return removed
+`)
+
+// RedisPresence 基于Redis hash的在线状态注册表
+type RedisPresence struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewRedisPresence 创建Redis在线状态注册表
+func NewRedisPresence(client *redis.Client) *RedisPresence {
+	return &RedisPresence{client: client, ctx: context.Background()}
+}
+
+func (p *RedisPresence) Online(userId string, deviceId string, nodeId string) error {
+	return p.client.HSet(p.ctx, presenceKeyPrefix+userId, deviceId, nodeId).Err()
+}
+
+func (p *RedisPresence) Offline(userId string, deviceId string, nodeId string) error {
+	return offlineScript.Run(p.ctx, p.client, []string{presenceKeyPrefix + userId}, deviceId, nodeId).Err()
+}
+
+// Nodes 通过pipeline一次往返获取所有用户的登记
+func (p *RedisPresence) Nodes(userIds ...string) (map[string][]string, error) {
+	pipe := p.client.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, 0, len(userIds))
+	for _, userId := range userIds {
+		cmds = append(cmds, pipe.HGetAll(p.ctx, presenceKeyPrefix+userId))
+	}
+	if _, err := pipe.Exec(p.ctx); err != nil {
+		return nil, err
+	}
+	nodes := make(map[string][]string)
+	for i, cmd := range cmds {
+		if devices := cmd.Val(); len(devices) > 0 {
+			nodes[userIds[i]] = distinctNodes(devices)
+		}
+	}
+	return nodes, nil
+}
+
+func (p *RedisPresence) RemoveNode(userId string, nodeId string) error {
+	return removeNodeScript.Run(p.ctx, p.client, []string{presenceKeyPrefix + userId}, nodeId).Err()
+}
+
+// RedisBus 基于Redis发布订阅的消息总线，每个节点订阅自己的频道
+type RedisBus struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewRedisBus 创建Redis消息总线
+func NewRedisBus(client *redis.Client) *RedisBus {
+	return &RedisBus{client: client, ctx: context.Background()}
+}
+
+func (b *RedisBus) Publish(nodeId string, envelope *Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	receivers, err := b.client.Publish(b.ctx, nodeChannelPrefix+nodeId, data).Result()
+	if err != nil {
+		return err
+	}
+	if receivers == 0 {
+		return ErrNodeUnreachable
+	}
+	return nil
+}
+
+func (b *RedisBus) Subscribe(nodeId string, handler func(envelope *Envelope)) (func(), error) {
+	sub := b.client.Subscribe(b.ctx, nodeChannelPrefix+nodeId)
+	// 等待订阅确认，保证返回后发过来的消息不会丢失
+	if _, err := sub.Receive(b.ctx); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+	go func() {
+		for msg := range sub.Channel() {
+			var envelope Envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+				continue
+			}
+			handler(&envelope)
+		}
+	}()
+	return func() {
+		_ = sub.Close()
+	}, nil
+}
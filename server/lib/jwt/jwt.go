@@ -0,0 +1,74 @@
+package jwt
+
+import (
+	"Kama-Chat/global"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+var (
+	// ErrTokenExpired token已过期
+	ErrTokenExpired = errors.New("token已过期")
+	// ErrTokenInvalid token无效（签名错误、格式错误等）
+	ErrTokenInvalid = errors.New("token无效")
+)
+
+// CustomClaims token中携带的载荷
+type CustomClaims struct {
+	// Uuid 用户唯一id，服务端以此作为调用方身份
+	Uuid string `json:"uuid"`
+	jwt.RegisteredClaims
+}
+
+// GenerateToken 为指定用户签发访问token
+// 返回token字符串以及过期时间
+func GenerateToken(uuid string) (string, time.Time, error) {
+	jwtConfig := global.CONFIG.JwtConfig
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(jwtConfig.ExpiresTime) * time.Hour)
+	claims := CustomClaims{
+		Uuid: uuid,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    jwtConfig.Issuer,
+			Subject:   uuid,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(jwtConfig.SigningKey))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
+
+// ParseToken 校验并解析token
+// 只接受HS256签名，过期返回ErrTokenExpired，其余错误统一返回ErrTokenInvalid
+func ParseToken(tokenString string) (*CustomClaims, error) {
+	jwtConfig := global.CONFIG.JwtConfig
+	claims := &CustomClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(jwtConfig.SigningKey), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(jwtConfig.Issuer))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrTokenInvalid
+	}
+	if !token.Valid || claims.Uuid == "" {
+		return nil, ErrTokenInvalid
+	}
+	return claims, nil
+}
+
+// NeedRefresh 判断token剩余有效期是否已进入续签窗口
+func NeedRefresh(claims *CustomClaims) bool {
+	if claims.ExpiresAt == nil {
+		return false
+	}
+	return time.Until(claims.ExpiresAt.Time) < time.Duration(global.CONFIG.JwtConfig.BufferTime)*time.Hour
+}
@@ -0,0 +1,42 @@
+package password
+
+import (
+	"crypto/subtle"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// cost bcrypt计算强度，每加1耗时翻倍
+const cost = 12
+
+// Hash 使用bcrypt对明文密码加盐哈希，盐值包含在返回结果中
+func Hash(plain string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// IsHashed 判断数据库中保存的密码是否已经是bcrypt哈希
+// 历史数据是明文保存的，长度不超过18位，不会以$2a$/$2b$/$2y$开头
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// Verify 校验明文密码是否与数据库中保存的密码一致
+// 兼容尚未迁移的明文密码，needRehash为true表示校验通过但需要重新哈希后写回数据库
+func Verify(stored string, plain string) (ok bool, needRehash bool) {
+	if IsHashed(stored) {
+		if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(plain)); err != nil {
+			return false, false
+		}
+		hashCost, err := bcrypt.Cost([]byte(stored))
+		return true, err == nil && hashCost < cost
+	}
+	// 明文密码，使用常量时间比较防止时序攻击
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(plain)) != 1 {
+		return false, false
+	}
+	return true, true
+}
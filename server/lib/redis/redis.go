@@ -45,6 +45,11 @@ func InitRedis() {
 
 }
 
+// GetClient 获取Redis客户端，供需要发布订阅、脚本等原生命令的模块使用
+func GetClient() *redis.Client {
+	return redisClient
+}
+
 // SetKeyEx 设置Redis键的值，并指定过期时间。
 // 该函数用于在Redis中存储一个键值对，并且可以为该键设置一个过期时间。
 func SetKeyEx(key string, value string, timeout time.Duration) error {
@@ -58,6 +63,12 @@ func SetKeyEx(key string, value string, timeout time.Duration) error {
 	return nil
 }
 
+// SetKeyNX 在键不存在时设置键的值和过期时间，返回是否设置成功。
+// 用于多个实例之间争抢同一件事的处理权，键已存在说明已经被其他实例占用。
+func SetKeyNX(key string, value string, timeout time.Duration) (bool, error) {
+	return redisClient.SetNX(ctx, key, value, timeout).Result()
+}
+
 // GetKey 从Redis中获取指定key的值。
 func GetKey(key string) (string, error) {
 	// 使用redisClient.Get方法从Redis中获取键对应的值。
@@ -0,0 +1,93 @@
+package search
+
+import (
+	"Kama-Chat/model"
+	"Kama-Chat/utils/enum"
+	"gorm.io/gorm"
+	"strings"
+	"unicode/utf8"
+)
+
+// ngramTokenSize MySQL ngram分词的长度，使用默认值2，更短的词无法走全文索引
+const ngramTokenSize = 2
+
+// MysqlIndexer 基于MySQL FULLTEXT索引的实现，message.content 上建有ngram分词的全文索引，中文可以按词匹配
+// 索引由MySQL在写入时维护，不依赖外部服务
+type MysqlIndexer struct {
+	db *gorm.DB
+}
+
+// NewMysqlIndexer 创建MySQL全文索引
+func NewMysqlIndexer(db *gorm.DB) *MysqlIndexer {
+	return &MysqlIndexer{db: db}
+}
+
+// Index 全文索引随message表的写入更新，不需要额外处理
+func (m *MysqlIndexer) Index(message model.Message) error {
+	return nil
+}
+
+// Remove 撤回的消息在搜索时按撤回时间排除，不需要额外处理
+func (m *MysqlIndexer) Remove(messageUuid string) error {
+	return nil
+}
+
+// Search 每个搜索词作为短语必须出现；短于分词长度的词无法走全文索引，改用LIKE匹配
+func (m *MysqlIndexer) Search(query Query) ([]string, int64, error) {
+	terms := Terms(query.Keyword)
+	if len(terms) == 0 || len(query.ConversationIds) == 0 {
+		return nil, 0, nil
+	}
+	var phrases []string
+	var short []string
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < ngramTokenSize {
+			short = append(short, term)
+		} else {
+			phrases = append(phrases, `+"`+term+`"`)
+		}
+	}
+	// build 每次调用都返回一个新的查询，分别用于计数和取一页
+	build := func() *gorm.DB {
+		db := m.db.Model(&model.Message{}).
+			Where("conversation_id IN ? AND recalled_at IS NULL AND type != ?", query.ConversationIds, enum.AudioOrVideo)
+		if query.UserId != "" {
+			db = db.Where("uuid NOT IN (?)", m.db.Model(&model.MessageHidden{}).Select("message_uuid").Where("user_id = ?", query.UserId))
+		}
+		if len(phrases) > 0 {
+			db = db.Where("MATCH(content) AGAINST(? IN BOOLEAN MODE)", strings.Join(phrases, " "))
+		}
+		for _, term := range short {
+			db = db.Where("content LIKE ?", "%"+escapeLike(term)+"%")
+		}
+		if query.SendId != "" {
+			db = db.Where("send_id = ?", query.SendId)
+		}
+		if query.Type != nil {
+			db = db.Where("type = ?", *query.Type)
+		}
+		if !query.StartTime.IsZero() {
+			db = db.Where("created_at >= ?", query.StartTime)
+		}
+		if !query.EndTime.IsZero() {
+			db = db.Where("created_at < ?", query.EndTime)
+		}
+		return db
+	}
+	var total int64
+	if res := build().Count(&total); res.Error != nil {
+		return nil, 0, res.Error
+	}
+	var uuids []string
+	if total > 0 {
+		if res := build().Order("id DESC").Offset(query.Offset).Limit(query.Limit).Pluck("uuid", &uuids); res.Error != nil {
+			return nil, 0, res.Error
+		}
+	}
+	return uuids, total, nil
+}
+
+// escapeLike 转义LIKE中的通配符
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+}
@@ -0,0 +1,114 @@
+package search
+
+import (
+	"Kama-Chat/model"
+	"html"
+	"strings"
+	"time"
+)
+
+// Query 消息搜索条件
+type Query struct {
+	// UserId 搜索者uuid，搜索者删除的消息不返回
+	UserId string
+	// Keyword 搜索内容，多个词用空格分隔，消息需要包含所有词
+	Keyword string
+	// ConversationIds 允许搜索的会话id，由调用方按搜索者所在的会话确定，为空时不返回任何结果
+	ConversationIds []string
+	// SendId 发送者uuid，为空时不限制
+	SendId string
+	// Type 消息类型，为nil时不限制
+	Type *int8
+	// StartTime、EndTime 消息发送时间范围，零值表示不限制
+	StartTime time.Time
+	EndTime   time.Time
+	Offset    int
+	Limit     int
+}
+
+// Indexer 消息搜索索引
+// 消息入库、编辑后调用 Index，撤回后调用 Remove；由数据库自身维护索引的实现可以不做任何事
+type Indexer interface {
+	// Index 建立或更新消息的索引
+	Index(message model.Message) error
+	// Remove 删除消息的索引
+	Remove(messageUuid string) error
+	// Search 按条件搜索消息，返回按发送时间倒序的消息uuid和匹配的总条数
+	Search(query Query) ([]string, int64, error)
+}
+
+// highlightRadius 高亮摘要在第一个匹配位置前后保留的字符数
+const highlightRadius = 20
+
+// Terms 把搜索内容拆分为搜索词，去掉全文检索的运算符和重复的词
+func Terms(keyword string) []string {
+	keyword = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`"+-<>()~*@`, r) {
+			return ' '
+		}
+		return r
+	}, keyword)
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(keyword) {
+		lower := strings.ToLower(term)
+		if !seen[lower] {
+			seen[lower] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Highlight 截取第一个匹配位置附近的内容，并用<em>标出所有搜索词，忽略大小写
+// 消息内容先做html转义，前端可以直接渲染结果
+func Highlight(content string, terms []string) string {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	// 转小写后个别字符的长度会变化，此时按原文匹配
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+	// matched 标记每个字符是否属于某个搜索词
+	matched := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		word := []rune(strings.ToLower(term))
+		if len(word) == 0 {
+			continue
+		}
+		for i := 0; i+len(word) <= len(lower); i++ {
+			if string(lower[i:i+len(word)]) != string(word) {
+				continue
+			}
+			for j := i; j < i+len(word); j++ {
+				matched[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+	start := 0
+	if first >= 0 {
+		start = max(0, first-highlightRadius)
+	}
+	end := min(len(runes), start+3*highlightRadius)
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("...")
+	}
+	for i := start; i < end; i++ {
+		if matched[i] && (i == start || !matched[i-1]) {
+			builder.WriteString("<em>")
+		}
+		builder.WriteString(html.EscapeString(string(runes[i])))
+		if matched[i] && (i == end-1 || !matched[i+1]) {
+			builder.WriteString("</em>")
+		}
+	}
+	if end < len(runes) {
+		builder.WriteString("...")
+	}
+	return builder.String()
+}
@@ -30,22 +30,22 @@ func main() {
 	zlog.InitLogger()
 	// 3. 数据库初始化
 	dao.InitMysql()
+	// 消息搜索索引初始化
+	chat.InitSearch()
 	// 4. Redis初始化
 	myredis.InitRedis()
 
-	// 5. kafka初始化
-	chat.InitKafka()
-	// 5. kafka初始化
+	// 集群节点初始化，未开启集群时不做任何事
+	chat.InitCluster()
+
+	// 5. kafka初始化，kafka模式下聊天消息经过kafka传输，默认使用进程内通道
 	if kafkaConfig.MessageMode == "kafka" {
 		kafka.KafkaService.KafkaInit()
+		chat.ChatServer.SetTransport(chat.NewKafkaTransport())
 	}
 
-	// 6. 启动kafka服务
-	if kafkaConfig.MessageMode == "channel" {
-		go chat.ChatServer.Start()
-	} else {
-		go chat.KafkaChatServer.Start()
-	}
+	// 6. 启动聊天服务
+	go chat.ChatServer.Start()
 
 	go func() {
 		// Win10本地部署
@@ -76,7 +76,7 @@ func main() {
 	log.Println("web 服务器启动成功： ", address)
 
 	// 等待中断信号以优雅地关闭服务器（设置 5 秒的超时时间）
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
@@ -101,20 +101,21 @@ func main() {
 
 	fmt.Println("program exit ok")
 
-	// 关闭kafka服务
-	if kafkaConfig.MessageMode == "kafka" {
-		kafka.KafkaService.KafkaClose()
-	}
-
-	//chat.ChatServer.Close()
+	// 关闭消息传输层，kafka模式下同时关闭kafka服务
+	chat.ChatServer.Close()
 
 	zlog.Info("关闭服务器...")
 
-	// 删除所有Redis键
-	if err := myredis.DeleteAllRedisKeys(); err != nil {
-		zlog.Error(err.Error())
+	// 集群模式下Redis由所有节点共享，只注销本节点的在线登记，不能清空
+	if conf.ClusterConfig.Enable {
+		chat.CloseCluster()
 	} else {
-		zlog.Info("所有Redis键已删除")
+		// 删除所有Redis键
+		if err := myredis.DeleteAllRedisKeys(); err != nil {
+			zlog.Error(err.Error())
+		} else {
+			zlog.Info("所有Redis键已删除")
+		}
 	}
 
 	zlog.Info("服务器已关闭")
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	myjwt "Kama-Chat/lib/jwt"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ctxUserIdKey gin上下文中保存调用方uuid的键
+const ctxUserIdKey = "kama_user_id"
+
+// JwtAuth 创建token校验中间件。
+// 依次从 Authorization: Bearer xxx、X-Token 请求头以及 token 查询参数中读取token，
+// 校验通过后把token中的用户uuid写入上下文，后续handler通过 GetUserId 获取调用方身份，
+// 而不是相信请求体里传上来的 owner_id/user_id 等字段。
+// 当token剩余有效期小于配置的缓冲时间时，会在响应头 New-Token/New-Expires-At 中下发新token。
+func JwtAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := GetToken(c)
+		if tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusOK, gin.H{
+				"code":    401,
+				"message": "未登录或非法访问",
+			})
+			return
+		}
+		claims, err := myjwt.ParseToken(tokenString)
+		if err != nil {
+			message := "token无效，请重新登录"
+			if errors.Is(err, myjwt.ErrTokenExpired) {
+				message = "登录已过期，请重新登录"
+			}
+			c.AbortWithStatusJSON(http.StatusOK, gin.H{
+				"code":    401,
+				"message": message,
+			})
+			return
+		}
+		// 临近过期，续签
+		if myjwt.NeedRefresh(claims) {
+			if newToken, expiresAt, err := myjwt.GenerateToken(claims.Uuid); err == nil {
+				c.Header("New-Token", newToken)
+				c.Header("New-Expires-At", strconv.FormatInt(expiresAt.Unix(), 10))
+			}
+		}
+		c.Set(ctxUserIdKey, claims.Uuid)
+		c.Next()
+	}
+}
+
+// GetToken 从请求中读取token
+func GetToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); auth != "" {
+		if strings.HasPrefix(auth, "Bearer ") {
+			return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+		return strings.TrimSpace(auth)
+	}
+	if token := c.GetHeader("X-Token"); token != "" {
+		return token
+	}
+	return c.Query("token")
+}
+
+// GetUserId 获取经过token校验的调用方uuid，未经过 JwtAuth 的路由返回空字符串
+func GetUserId(c *gin.Context) string {
+	return c.GetString(ctxUserIdKey)
+}
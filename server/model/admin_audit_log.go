@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// AdminAuditLog 管理操作审计记录，被拒绝的越权请求也会记录
+type AdminAuditLog struct {
+	Id           int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	OperatorId   string    `gorm:"column:operator_id;index;type:char(20);not null;comment:操作者id"`
+	OperatorRole int8      `gorm:"column:operator_role;not null;comment:操作时的角色，0.普通用户，1.管理员，2.超级管理员"`
+	Permission   string    `gorm:"column:permission;type:varchar(50);comment:所需权限点"`
+	Path         string    `gorm:"column:path;type:varchar(100);not null;comment:请求路径"`
+	Params       string    `gorm:"column:params;type:text;comment:请求参数"`
+	ClientIp     string    `gorm:"column:client_ip;type:varchar(64);comment:客户端ip"`
+	Code         int       `gorm:"column:code;comment:响应code，403表示无权限"`
+	Message      string    `gorm:"column:message;type:varchar(255);comment:响应信息"`
+	CreatedAt    time.Time `gorm:"column:created_at;index;type:datetime;not null;comment:操作时间"`
+}
+
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_log"
+}
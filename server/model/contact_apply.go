@@ -1,6 +1,7 @@
 package model
 
 import (
+	"database/sql"
 	"gorm.io/gorm"
 	"time"
 )
@@ -14,6 +15,8 @@ type ContactApply struct {
 	Status      int8           `gorm:"column:status;not null;comment:申请状态，0.申请中，1.通过，2.拒绝，3.拉黑"`
 	Message     string         `gorm:"column:message;type:varchar(100);comment:申请信息"`
 	LastApplyAt time.Time      `gorm:"column:last_apply_at;type:datetime;not null;comment:最后申请时间"`
+	HandledBy   string         `gorm:"column:handled_by;type:char(20);comment:处理人uuid，只用于加群申请"`
+	HandledAt   sql.NullTime   `gorm:"column:handled_at;comment:处理时间，只用于加群申请"`
 	DeletedAt   gorm.DeletedAt `gorm:"column:deleted_at;index;type:datetime;comment:删除时间"`
 }
 
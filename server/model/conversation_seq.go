@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// ConversationSeq 会话当前已分配的最大消息序号，单聊双方共用一个会话，群聊以群为会话
+type ConversationSeq struct {
+	Id             int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	ConversationId string    `gorm:"column:conversation_id;uniqueIndex;type:varchar(41);not null;comment:会话id"`
+	Seq            int64     `gorm:"column:seq;not null;default:0;comment:已分配的最大序号"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;type:datetime;not null;comment:更新时间"`
+}
+
+func (ConversationSeq) TableName() string {
+	return "conversation_seq"
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// DeviceCursor 设备的消息确认进度，设备重连时补发进度之后的消息
+type DeviceCursor struct {
+	Id        int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	UserId    string    `gorm:"column:user_id;uniqueIndex:idx_user_device;type:char(20);not null;comment:用户uuid"`
+	DeviceId  string    `gorm:"column:device_id;uniqueIndex:idx_user_device;type:varchar(64);not null;comment:设备id"`
+	LastAckId int64     `gorm:"column:last_ack_id;not null;default:0;comment:确认进度，该自增id及之前的消息都已确认"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:datetime;not null;comment:更新时间"`
+}
+
+func (DeviceCursor) TableName() string {
+	return "device_cursor"
+}
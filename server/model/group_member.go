@@ -0,0 +1,22 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GroupMember 群成员，每个成员一行，退群或被移出时删除
+type GroupMember struct {
+	Id        int64        `gorm:"column:id;primaryKey;comment:自增id"`
+	GroupId   string       `gorm:"column:group_id;uniqueIndex:idx_group_user;type:char(20);not null;comment:群聊uuid"`
+	UserId    string       `gorm:"column:user_id;uniqueIndex:idx_group_user;index;type:char(20);not null;comment:成员uuid"`
+	Role      int8         `gorm:"column:role;not null;default:0;comment:群内角色，0.成员，1.管理员，2.群主"`
+	InviterId string       `gorm:"column:inviter_id;type:char(20);comment:邀请人uuid，主动加群时为空"`
+	Nickname  string       `gorm:"column:nickname;type:varchar(20);comment:群昵称"`
+	MuteUntil sql.NullTime `gorm:"column:mute_until;comment:禁言截止时间"`
+	JoinedAt  time.Time    `gorm:"column:joined_at;type:datetime;not null;comment:入群时间"`
+}
+
+func (GroupMember) TableName() string {
+	return "group_member"
+}
@@ -9,20 +9,34 @@ type Message struct {
 	Id         int64        `gorm:"column:id;primaryKey;comment:自增id"`
 	Uuid       string       `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:消息uuid"`
 	SessionId  string       `gorm:"column:session_id;index;type:char(20);not null;comment:会话uuid"`
-	Type       int8         `gorm:"column:type;not null;comment:消息类型，0.文本，1.语音，2.文件，3.通话"` // 通话不用存消息内容或者url
-	Content    string       `gorm:"column:content;type:TEXT;comment:消息内容"`
+	Type       int8         `gorm:"column:type;not null;comment:消息类型，0.文本，1.语音，2.文件，3.通话，4.系统通知"` // 通话不用存消息内容或者url
+	Content    string       `gorm:"column:content;type:TEXT;index:idx_message_content,class:FULLTEXT,option:WITH PARSER ngram;comment:消息内容"`
 	Url        string       `gorm:"column:url;type:char(255);comment:消息url"`
-	SendId     string       `gorm:"column:send_id;index;type:char(20);not null;comment:发送者uuid"`
+	SendId     string       `gorm:"column:send_id;index;index:idx_send_client_msg,priority:1;type:char(20);not null;comment:发送者uuid"`
 	SendName   string       `gorm:"column:send_name;type:varchar(20);not null;comment:发送者昵称"`
 	SendAvatar string       `gorm:"column:send_avatar;type:varchar(255);not null;comment:发送者头像"`
 	ReceiveId  string       `gorm:"column:receive_id;index;type:char(20);not null;comment:接受者uuid"`
 	FileType   string       `gorm:"column:file_type;type:char(10);comment:文件类型"`
 	FileName   string       `gorm:"column:file_name;type:varchar(50);comment:文件名"`
 	FileSize   string       `gorm:"column:file_size;type:char(20);comment:文件大小"`
-	Status     int8         `gorm:"column:status;not null;comment:状态，0.未发送，1.已发送"`
+	Status     int8         `gorm:"column:status;not null;comment:状态，0.未发送，1.已发送，2.已读"`
 	CreatedAt  time.Time    `gorm:"column:created_at;not null;comment:创建时间"`
 	SendAt     sql.NullTime `gorm:"column:send_at;comment:发送时间"`
 	AVdata     string       `gorm:"column:av_data;comment:通话传递数据"`
+	RecalledAt sql.NullTime `gorm:"column:recalled_at;comment:撤回时间"`
+	EditedAt   sql.NullTime `gorm:"column:edited_at;comment:最近编辑时间"`
+	ReplyTo    string       `gorm:"column:reply_to;index;type:char(20);comment:引用的消息uuid"`
+	ThreadId   string       `gorm:"column:thread_id;index;type:char(20);comment:所属话题的根消息uuid，只用于群聊"`
+	ReplyCount int64        `gorm:"column:reply_count;not null;default:0;comment:话题回复数，只用于群聊话题的根消息"`
+	Mentions   string       `gorm:"column:mentions;type:TEXT;comment:被@的用户uuid，json数组，只用于群聊"`
+	MentionAll bool         `gorm:"column:mention_all;not null;default:false;comment:是否@所有人"`
+	// 会话内的消息序号从1开始连续递增，前端据此发现缺失的消息并增量同步
+	ConversationId string `gorm:"column:conversation_id;uniqueIndex:idx_conversation_seq;type:varchar(41);not null;default:'';comment:会话id，群聊为群uuid，单聊为双方uuid按字典序拼接"`
+	Seq            int64  `gorm:"column:seq;uniqueIndex:idx_conversation_seq;not null;default:0;comment:会话内的消息序号"`
+	ClientMsgId    string `gorm:"column:client_msg_id;index:idx_send_client_msg,priority:2;type:varchar(64);not null;default:'';comment:客户端生成的消息id，用于重发去重"`
+	// 语音消息的时长和波形，发送时校验后随消息保存
+	Duration int    `gorm:"column:duration;not null;default:0;comment:语音时长，单位秒"`
+	Waveform string `gorm:"column:waveform;type:varchar(512);comment:语音波形，json数组，每个采样点取值0-100"`
 }
 
 func (Message) TableName() string {
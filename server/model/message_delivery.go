@@ -0,0 +1,22 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// MessageDelivery 消息在每个设备上的投递状态，只记录开启了确认机制的设备
+type MessageDelivery struct {
+	Id          int64        `gorm:"column:id;primaryKey;comment:自增id"`
+	MessageUuid string       `gorm:"column:message_uuid;uniqueIndex:idx_message_device;type:char(20);not null;comment:消息uuid"`
+	UserId      string       `gorm:"column:user_id;uniqueIndex:idx_message_device;index:idx_device_status;type:char(20);not null;comment:接收用户uuid"`
+	DeviceId    string       `gorm:"column:device_id;uniqueIndex:idx_message_device;index:idx_device_status;type:varchar(64);not null;comment:设备id"`
+	Status      int8         `gorm:"column:status;index:idx_device_status;not null;comment:投递状态，0.待确认，1.已确认"`
+	RetryCount  int          `gorm:"column:retry_count;not null;default:0;comment:重发次数"`
+	LastSentAt  time.Time    `gorm:"column:last_sent_at;type:datetime;not null;comment:最近推送时间"`
+	AckedAt     sql.NullTime `gorm:"column:acked_at;type:datetime;comment:确认时间"`
+}
+
+func (MessageDelivery) TableName() string {
+	return "message_delivery"
+}
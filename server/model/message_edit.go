@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// MessageEdit 消息的编辑历史，每次编辑记录编辑前的内容
+type MessageEdit struct {
+	Id          int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	MessageUuid string    `gorm:"column:message_uuid;index;type:char(20);not null;comment:消息uuid"`
+	Content     string    `gorm:"column:content;type:TEXT;comment:编辑前的消息内容"`
+	EditedAt    time.Time `gorm:"column:edited_at;type:datetime;not null;comment:编辑时间"`
+}
+
+func (MessageEdit) TableName() string {
+	return "message_edit"
+}
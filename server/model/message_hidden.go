@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// MessageHidden 用户删除的消息，只对该用户隐藏，其他参与者不受影响
+type MessageHidden struct {
+	Id          int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	UserId      string    `gorm:"column:user_id;uniqueIndex:idx_user_message;type:char(20);not null;comment:用户uuid"`
+	MessageUuid string    `gorm:"column:message_uuid;uniqueIndex:idx_user_message;type:char(20);not null;comment:消息uuid"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:datetime;not null;comment:删除时间"`
+}
+
+func (MessageHidden) TableName() string {
+	return "message_hidden"
+}
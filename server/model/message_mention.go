@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// MessageMention 群聊消息中@到的用户，@所有人时为除发送者外的每个成员各记录一条
+type MessageMention struct {
+	Id          int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	MessageId   int64     `gorm:"column:message_id;not null;comment:消息自增id，与会话已读进度比较"`
+	MessageUuid string    `gorm:"column:message_uuid;uniqueIndex:idx_message_user;type:char(20);not null;comment:消息uuid"`
+	GroupId     string    `gorm:"column:group_id;index:idx_user_group;type:char(20);not null;comment:群聊uuid"`
+	UserId      string    `gorm:"column:user_id;uniqueIndex:idx_message_user;index:idx_user_group;type:char(20);not null;comment:被@的用户uuid"`
+	IsAll       bool      `gorm:"column:is_all;not null;default:false;comment:是否来自@所有人"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
+}
+
+func (MessageMention) TableName() string {
+	return "message_mention"
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// MessagePin 群聊中被置顶的消息，由群主或管理员置顶，全体群成员可见
+type MessagePin struct {
+	Id          int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	GroupId     string    `gorm:"column:group_id;index;type:char(20);not null;comment:群聊uuid"`
+	MessageUuid string    `gorm:"column:message_uuid;uniqueIndex;type:char(20);not null;comment:消息uuid"`
+	PinnedBy    string    `gorm:"column:pinned_by;type:char(20);not null;comment:置顶操作者uuid"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:datetime;not null;comment:置顶时间"`
+}
+
+func (MessagePin) TableName() string {
+	return "message_pin"
+}
@@ -1,6 +1,7 @@
 package request
 
 type AbleUsersRequest struct {
+	OwnerId  string   `json:"owner_id"`
 	UuidList []string `json:"uuid_list"`
 	IsAdmin  int8     `json:"is_admin"`
 }
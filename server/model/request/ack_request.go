@@ -0,0 +1,7 @@
+package request
+
+// AckRequest 客户端确认收到消息的websocket帧
+type AckRequest struct {
+	Event      string   `json:"event"`
+	MessageIds []string `json:"message_ids"`
+}
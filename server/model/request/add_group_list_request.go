@@ -1,5 +1,6 @@
 package request
 
 type AddGroupListRequest struct {
+	OwnerId string `json:"owner_id"`
 	GroupId string `json:"group_id"`
 }
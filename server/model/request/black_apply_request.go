@@ -1,6 +1,7 @@
 package request
 
 type BlackApplyRequest struct {
-	OwnerId   string `json:"owner_id"`
-	ContactId string `json:"contact_id"`
+	OwnerId    string `json:"owner_id"`
+	ContactId  string `json:"contact_id"`
+	OperatorId string `json:"-"` // 处理申请的用户，以token为准
 }
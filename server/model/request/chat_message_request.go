@@ -1,16 +1,24 @@
 package request
 
 type ChatMessageRequest struct {
-	SessionId  string `json:"session_id"`
-	Type       int8   `json:"type"`
-	Content    string `json:"content"`
-	Url        string `json:"url"`
-	SendId     string `json:"send_id"`
-	SendName   string `json:"send_name"`
-	SendAvatar string `json:"send_avatar"`
-	ReceiveId  string `json:"receive_id"`
-	FileSize   string `json:"file_size"`
-	FileType   string `json:"file_type"`
-	FileName   string `json:"file_name"`
-	AVdata     string `json:"av_data"`
+	SessionId  string   `json:"session_id"`
+	Type       int8     `json:"type"`
+	Content    string   `json:"content"`
+	Url        string   `json:"url"`
+	SendId     string   `json:"send_id"`
+	SendName   string   `json:"send_name"`
+	SendAvatar string   `json:"send_avatar"`
+	ReceiveId  string   `json:"receive_id"`
+	FileSize   string   `json:"file_size"`
+	FileType   string   `json:"file_type"`
+	FileName   string   `json:"file_name"`
+	AVdata     string   `json:"av_data"`
+	ReplyTo    string   `json:"reply_to"`
+	Mentions   []string `json:"mentions"`    // 群聊中@的用户uuid
+	MentionAll bool     `json:"mention_all"` // @所有人，只有群主和管理员可以使用
+	// ClientMsgId 客户端生成的消息id，可选，网络抖动后重发时带上相同的id，服务端只保存一次
+	ClientMsgId string `json:"client_msg_id"`
+	// Duration、Waveform 语音消息的时长和波形，取上传语音接口返回的值
+	Duration int   `json:"duration"`
+	Waveform []int `json:"waveform"`
 }
@@ -0,0 +1,7 @@
+package request
+
+type EditMessageRequest struct {
+	OwnerId   string `json:"owner_id"`
+	MessageId string `json:"message_id"`
+	Content   string `json:"content"`
+}
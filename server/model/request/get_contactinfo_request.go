@@ -1,5 +1,6 @@
 package request
 
 type GetContactInfoRequest struct {
+	OwnerId   string `json:"owner_id"`
 	ContactId string `json:"contact_id"`
 }
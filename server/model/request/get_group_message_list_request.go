@@ -1,5 +1,12 @@
 package request
 
+// GetGroupMessageListRequest 获取群聊记录，游标的含义与 GetMessageListRequest 相同
 type GetGroupMessageListRequest struct {
-	GroupId string `json:"group_id"`
+	OwnerId  string `json:"owner_id"`
+	GroupId  string `json:"group_id"`
+	Before   string `json:"before"`
+	After    string `json:"after"`
+	Around   string `json:"around"`
+	AfterSeq int64  `json:"after_seq"`
+	Limit    int    `json:"limit"`
 }
@@ -1,6 +1,14 @@
 package request
 
+// GetMessageListRequest 获取单聊记录
+// Before、After、Around 为消息uuid，最多传一个：分别获取该消息之前、之后的一页，或以该消息为中心的一页；都不传时获取最近一页
+// AfterSeq 大于0时获取会话内序号大于它的一页，用于前端按序号增量同步，此时忽略其他游标
 type GetMessageListRequest struct {
 	UserOneId string `json:"user_one_id"`
 	UserTwoId string `json:"user_two_id"`
+	Before    string `json:"before"`
+	After     string `json:"after"`
+	Around    string `json:"around"`
+	AfterSeq  int64  `json:"after_seq"`
+	Limit     int    `json:"limit"`
 }
@@ -0,0 +1,6 @@
+package request
+
+type GetPinnedMessageListRequest struct {
+	OwnerId string `json:"owner_id"`
+	GroupId string `json:"group_id"`
+}
@@ -0,0 +1,9 @@
+package request
+
+// MuteGroupMemberRequest 禁言群成员，Duration 为禁言秒数，-1表示永久禁言，0表示解除禁言
+type MuteGroupMemberRequest struct {
+	OwnerId  string `json:"owner_id"`
+	GroupId  string `json:"group_id"`
+	UserId   string `json:"user_id"`
+	Duration int64  `json:"duration"`
+}
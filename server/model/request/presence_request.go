@@ -0,0 +1,7 @@
+package request
+
+// PresenceRequest 查询联系人在线状态的websocket帧
+type PresenceRequest struct {
+	Event   string   `json:"event"`
+	UserIds []string `json:"user_ids"`
+}
@@ -0,0 +1,8 @@
+package request
+
+// ReactionRequest 添加或取消表情回应的websocket帧
+type ReactionRequest struct {
+	Event     string `json:"event"`
+	MessageId string `json:"message_id"`
+	Emoji     string `json:"emoji"`
+}
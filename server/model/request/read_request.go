@@ -0,0 +1,8 @@
+package request
+
+// ReadRequest 客户端上报已读的websocket帧，表示会话中直到 MessageId 的消息都已读
+type ReadRequest struct {
+	Event     string `json:"event"`
+	ReceiveId string `json:"receive_id"`
+	MessageId string `json:"message_id"`
+}
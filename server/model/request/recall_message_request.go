@@ -0,0 +1,6 @@
+package request
+
+type RecallMessageRequest struct {
+	OwnerId   string `json:"owner_id"`
+	MessageId string `json:"message_id"`
+}
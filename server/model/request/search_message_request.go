@@ -0,0 +1,14 @@
+package request
+
+// SearchMessageRequest 搜索聊天记录，除 Keyword 外的条件都是可选的
+type SearchMessageRequest struct {
+	OwnerId        string `json:"owner_id"`
+	Keyword        string `json:"keyword"`
+	ConversationId string `json:"conversation_id"` // 单聊为对方uuid，群聊为群uuid
+	SendId         string `json:"send_id"`
+	Type           *int8  `json:"type"`
+	StartDate      string `json:"start_date"` // 格式为2006-01-02，包含当天
+	EndDate        string `json:"end_date"`   // 格式为2006-01-02，包含当天
+	Page           int    `json:"page"`
+	Limit          int    `json:"limit"`
+}
@@ -0,0 +1,8 @@
+package request
+
+type SetGroupAdminRequest struct {
+	OwnerId string `json:"owner_id"`
+	GroupId string `json:"group_id"`
+	UserId  string `json:"user_id"`
+	IsAdmin bool   `json:"is_admin"`
+}
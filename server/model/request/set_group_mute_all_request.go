@@ -0,0 +1,7 @@
+package request
+
+type SetGroupMuteAllRequest struct {
+	OwnerId string `json:"owner_id"`
+	GroupId string `json:"group_id"`
+	MuteAll bool   `json:"mute_all"`
+}
@@ -0,0 +1,7 @@
+package request
+
+type TransferGroupOwnerRequest struct {
+	OwnerId    string `json:"owner_id"`
+	GroupId    string `json:"group_id"`
+	NewOwnerId string `json:"new_owner_id"`
+}
@@ -0,0 +1,8 @@
+package request
+
+// TypingRequest 正在输入的websocket帧，Typing为false表示停止输入
+type TypingRequest struct {
+	Event     string `json:"event"`
+	ReceiveId string `json:"receive_id"`
+	Typing    bool   `json:"typing"`
+}
@@ -0,0 +1,7 @@
+package request
+
+type UpdatePasswordRequest struct {
+	Uuid        string `json:"uuid"`
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
@@ -0,0 +1,6 @@
+package request
+
+// WsEventRequest websocket事件帧的公共部分，根据 Event 再解析成具体的事件
+type WsEventRequest struct {
+	Event string `json:"event"`
+}
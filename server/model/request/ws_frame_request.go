@@ -0,0 +1,10 @@
+package request
+
+import "encoding/json"
+
+// FrameRequest 协议v2中客户端发送的信封帧，Type 为 message 或事件名，Id 由客户端生成，错误帧会原样带回
+type FrameRequest struct {
+	Type    string          `json:"type"`
+	Id      string          `json:"id"`
+	Payload json.RawMessage `json:"payload"`
+}
@@ -1,5 +1,6 @@
 package request
 
 type WsLogoutRequest struct {
-	OwnerId string `json:"owner_id"`
+	OwnerId  string `json:"owner_id"`
+	DeviceId string `json:"device_id"`
 }
@@ -1,6 +1,7 @@
 package respond
 
 type AVMessageRespond struct {
+	Uuid       string `json:"uuid"`
 	SendId     string `json:"send_id"`
 	SendName   string `json:"send_name"`
 	SendAvatar string `json:"send_avatar"`
@@ -16,4 +16,5 @@ type GetContactInfoRespond struct {
 	ContactMemberCnt int             `json:"contact_member_cnt"`
 	ContactOwnerId   string          `json:"contact_owner_id"`
 	ContactAddMode   int8            `json:"contact_add_mode"`
+	ContactMuteUntil string          `json:"contact_mute_until"` // 调用方在群里的禁言截止时间，未被禁言时为空
 }
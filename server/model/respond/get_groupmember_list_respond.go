@@ -1,7 +1,12 @@
 package respond
 
 type GetGroupMemberListRespond struct {
-	UserId   string `json:"user_id"`
-	Nickname string `json:"nickname"`
-	Avatar   string `json:"avatar"`
+	UserId        string `json:"user_id"`
+	Nickname      string `json:"nickname"`
+	Avatar        string `json:"avatar"`
+	Role          int8   `json:"role"`
+	GroupNickname string `json:"group_nickname"`
+	InviterId     string `json:"inviter_id"`
+	JoinedAt      string `json:"joined_at"`
+	MuteUntil     string `json:"mute_until"`
 }
@@ -0,0 +1,13 @@
+package respond
+
+type GetMessageEditHistoryRespond struct {
+	MessageId string               `json:"message_id"`
+	Content   string               `json:"content"`
+	History   []MessageEditRespond `json:"history"`
+}
+
+// MessageEditRespond 一次编辑前的内容
+type MessageEditRespond struct {
+	Content  string `json:"content"`
+	EditedAt string `json:"edited_at"`
+}
@@ -1,15 +1,24 @@
 package respond
 
 type GetMessageListRespond struct {
-	SendId     string `json:"send_id"`
-	SendName   string `json:"send_name"`
-	SendAvatar string `json:"send_avatar"`
-	ReceiveId  string `json:"receive_id"`
-	Type       int8   `json:"type"`
-	Content    string `json:"content"`
-	Url        string `json:"url"`
-	FileType   string `json:"file_type"`
-	FileName   string `json:"file_name"`
-	FileSize   string `json:"file_size"`
-	CreatedAt  string `json:"created_at"` // 先用CreatedAt排序，后面考虑改成SentAt
+	Uuid       string                `json:"uuid"`
+	SendId     string                `json:"send_id"`
+	SendName   string                `json:"send_name"`
+	SendAvatar string                `json:"send_avatar"`
+	ReceiveId  string                `json:"receive_id"`
+	Type       int8                  `json:"type"`
+	Content    string                `json:"content"`
+	Url        string                `json:"url"`
+	FileType   string                `json:"file_type"`
+	FileName   string                `json:"file_name"`
+	FileSize   string                `json:"file_size"`
+	Duration   int                   `json:"duration"` // 语音时长，单位秒
+	Waveform   []int                 `json:"waveform"`
+	CreatedAt  string                `json:"created_at"` // 先用CreatedAt排序，后面考虑改成SentAt
+	Seq        int64                 `json:"seq"`        // 会话内的消息序号
+	IsRecalled bool                  `json:"is_recalled"`
+	IsEdited   bool                  `json:"is_edited"`
+	ReplyTo    string                `json:"reply_to"`
+	Quote      *QuotedMessageRespond `json:"quote"`
+	Reactions  []ReactionRespond     `json:"reactions"` // 因人而异，不写入缓存，获取时实时汇总
 }
@@ -0,0 +1,14 @@
+package respond
+
+type MessageReaderRespond struct {
+	UserId   string `json:"user_id"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+	ReadAt   string `json:"read_at"`
+}
+
+type GetMessageReadersRespond struct {
+	MessageId string                 `json:"message_id"`
+	ReadCount int                    `json:"read_count"`
+	Readers   []MessageReaderRespond `json:"readers"`
+}
@@ -0,0 +1,7 @@
+package respond
+
+type GetThreadMessageListRespond struct {
+	Root       GetGroupMessageListRespond   `json:"root"`
+	ReplyCount int64                        `json:"reply_count"`
+	Replies    []GetGroupMessageListRespond `json:"replies"`
+}
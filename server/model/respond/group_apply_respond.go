@@ -0,0 +1,35 @@
+package respond
+
+// GroupApplyListRespond 群主和管理员待处理的加群申请，包括自己管理的所有群聊
+type GroupApplyListRespond struct {
+	GroupId       string `json:"group_id"`
+	GroupName     string `json:"group_name"`
+	ContactId     string `json:"contact_id"`
+	ContactName   string `json:"contact_name"`
+	ContactAvatar string `json:"contact_avatar"`
+	Message       string `json:"message"`
+	LastApplyAt   string `json:"last_apply_at"`
+}
+
+// GroupApplyEventRespond 新的加群申请
+type GroupApplyEventRespond struct {
+	Event         string `json:"event"`
+	GroupId       string `json:"group_id"`
+	GroupName     string `json:"group_name"`
+	ContactId     string `json:"contact_id"`
+	ContactName   string `json:"contact_name"`
+	ContactAvatar string `json:"contact_avatar"`
+	Message       string `json:"message"`
+	LastApplyAt   string `json:"last_apply_at"`
+}
+
+// GroupApplyResultEventRespond 加群申请的处理结果，Status 为申请状态，HandledBy 为处理人
+type GroupApplyResultEventRespond struct {
+	Event     string `json:"event"`
+	GroupId   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+	ContactId string `json:"contact_id"`
+	Status    int8   `json:"status"`
+	HandledBy string `json:"handled_by"`
+	HandledAt string `json:"handled_at"`
+}
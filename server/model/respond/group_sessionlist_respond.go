@@ -1,8 +1,11 @@
 package respond
 
 type GroupSessionListRespond struct {
-	SessionId string `json:"session_id"`
-	GroupName string `json:"group_name"`
-	GroupId   string `json:"group_id"`
-	Avatar    string `json:"avatar"`
+	SessionId         string `json:"session_id"`
+	GroupName         string `json:"group_name"`
+	GroupId           string `json:"group_id"`
+	Avatar            string `json:"avatar"`
+	UnreadCount       int64  `json:"unread_count"`
+	MentionCount      int64  `json:"mention_count"` // 已读进度之后@到自己的消息数
+	LastReadMessageId string `json:"last_read_message_id"`
 }
@@ -12,4 +12,6 @@ type LoginRespond struct {
 	CreatedAt string `json:"created_at"`
 	IsAdmin   int8   `json:"is_admin"`
 	Status    int8   `json:"status"`
+	Token     string `json:"token"`      // 访问token，之后的请求放在 Authorization: Bearer 中
+	ExpiresAt int64  `json:"expires_at"` // token过期时间，unix秒
 }
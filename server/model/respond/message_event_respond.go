@@ -0,0 +1,11 @@
+package respond
+
+// MessageEventRespond 推送给前端的消息变更事件，撤回、编辑推送给会话参与者，删除只推送给自己的设备
+type MessageEventRespond struct {
+	Event     string `json:"event"`
+	MessageId string `json:"message_id"`
+	SendId    string `json:"send_id"`
+	ReceiveId string `json:"receive_id"`
+	Content   string `json:"content"`
+	UpdatedAt string `json:"updated_at"`
+}
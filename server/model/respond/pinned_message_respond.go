@@ -0,0 +1,9 @@
+package respond
+
+// PinnedMessageRespond 一条群聊置顶消息
+type PinnedMessageRespond struct {
+	MessageId string                `json:"message_id"`
+	PinnedBy  string                `json:"pinned_by"`
+	PinnedAt  string                `json:"pinned_at"`
+	Message   *QuotedMessageRespond `json:"message"`
+}
@@ -0,0 +1,11 @@
+package respond
+
+// QuotedMessageRespond 被引用消息的摘要
+type QuotedMessageRespond struct {
+	Uuid       string `json:"uuid"`
+	SendId     string `json:"send_id"`
+	SendName   string `json:"send_name"`
+	Type       int8   `json:"type"`
+	Snippet    string `json:"snippet"`
+	IsRecalled bool   `json:"is_recalled"`
+}
@@ -0,0 +1,18 @@
+package respond
+
+// ReactionRespond 某个表情的回应汇总，Reacted 表示当前用户是否回应过
+type ReactionRespond struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+// ReactionEventRespond 推送给会话参与者的表情回应变化，Count 为变化后该表情的回应数
+type ReactionEventRespond struct {
+	Event     string `json:"event"`
+	MessageId string `json:"message_id"`
+	ReceiveId string `json:"receive_id"`
+	UserId    string `json:"user_id"`
+	Emoji     string `json:"emoji"`
+	Count     int64  `json:"count"`
+}
@@ -0,0 +1,11 @@
+package respond
+
+// ReadEventRespond 推送给前端的已读回执
+// 单聊推送给对方和自己的其他设备，群聊只推送给自己的其他设备
+type ReadEventRespond struct {
+	Event     string `json:"event"`
+	ReaderId  string `json:"reader_id"`
+	ReceiveId string `json:"receive_id"`
+	MessageId string `json:"message_id"`
+	ReadAt    string `json:"read_at"`
+}
@@ -0,0 +1,21 @@
+package respond
+
+// SearchMessageRespond 搜索结果，Total 为匹配的总条数
+type SearchMessageRespond struct {
+	Total    int64                      `json:"total"`
+	Messages []SearchMessageItemRespond `json:"messages"`
+}
+
+// SearchMessageItemRespond 一条匹配的消息，Highlight 为带<em>标记的内容摘要
+type SearchMessageItemRespond struct {
+	Uuid       string `json:"uuid"`
+	SendId     string `json:"send_id"`
+	SendName   string `json:"send_name"`
+	SendAvatar string `json:"send_avatar"`
+	ReceiveId  string `json:"receive_id"`
+	Type       int8   `json:"type"`
+	Content    string `json:"content"`
+	Highlight  string `json:"highlight"`
+	CreatedAt  string `json:"created_at"`
+	Seq        int64  `json:"seq"`
+}
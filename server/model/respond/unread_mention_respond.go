@@ -0,0 +1,11 @@
+package respond
+
+// UnreadMentionRespond 一条未读的@提醒
+type UnreadMentionRespond struct {
+	GroupId   string                `json:"group_id"`
+	GroupName string                `json:"group_name"`
+	MessageId string                `json:"message_id"`
+	IsAll     bool                  `json:"is_all"`
+	Message   *QuotedMessageRespond `json:"message"`
+	CreatedAt string                `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package respond
+
+// UploadVoiceRespond 上传语音的结果，发送语音消息时原样带上这些字段
+type UploadVoiceRespond struct {
+	Url      string `json:"url"`
+	FileType string `json:"file_type"`
+	FileSize string `json:"file_size"`
+	Duration int    `json:"duration"`
+	Waveform []int  `json:"waveform"`
+}
@@ -0,0 +1,44 @@
+package respond
+
+// NoticeEventRespond 连接建立、退出登录等通知，Version 为连接协商的协议版本
+type NoticeEventRespond struct {
+	Event    string `json:"event"`
+	DeviceId string `json:"device_id"`
+	Version  int    `json:"version"`
+	Message  string `json:"message"`
+}
+
+// PresenceEventRespond 在线状态，上下线推送和在线状态查询的回复都使用该结构
+type PresenceEventRespond struct {
+	Event         string `json:"event"`
+	UserId        string `json:"user_id"`
+	Online        bool   `json:"online"`
+	LastOnlineAt  string `json:"last_online_at"`
+	LastOfflineAt string `json:"last_offline_at"`
+}
+
+// SendAckEventRespond 消息已保存的回执，Duplicate 表示这是重发的消息，返回的是第一次保存的消息
+type SendAckEventRespond struct {
+	Event       string `json:"event"`
+	ClientMsgId string `json:"client_msg_id"`
+	MessageId   string `json:"message_id"`
+	ReceiveId   string `json:"receive_id"`
+	Seq         int64  `json:"seq"`
+	Duplicate   bool   `json:"duplicate"`
+}
+
+// ErrorEventRespond 消息被拒绝，Code 为 enum 中的错误码，ClientMsgId 为被拒绝消息的客户端消息id
+type ErrorEventRespond struct {
+	Event       string `json:"event"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	ClientMsgId string `json:"client_msg_id"`
+}
+
+// TypingEventRespond 正在输入
+type TypingEventRespond struct {
+	Event     string `json:"event"`
+	SendId    string `json:"send_id"`
+	ReceiveId string `json:"receive_id"`
+	Typing    bool   `json:"typing"`
+}
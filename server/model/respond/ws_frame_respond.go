@@ -0,0 +1,18 @@
+package respond
+
+import "encoding/json"
+
+// FrameRespond 协议v2中服务端推送的信封帧，出错时只有 Error 没有 Payload
+type FrameRespond struct {
+	Version int                `json:"version"`
+	Type    string             `json:"type"`
+	Id      string             `json:"id"`
+	Payload json.RawMessage    `json:"payload,omitempty"`
+	Error   *FrameErrorRespond `json:"error,omitempty"`
+}
+
+// FrameErrorRespond 信封帧中的错误，Code 为 enum 中的错误码
+type FrameErrorRespond struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
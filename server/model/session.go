@@ -15,6 +15,9 @@ type Session struct {
 	Avatar        string         `gorm:"column:avatar;type:char(255);default:default_avatar.png;not null;comment:头像"`
 	LastMessage   string         `gorm:"column:last_message;type:TEXT;comment:最新的消息"`
 	LastMessageAt sql.NullTime   `gorm:"column:last_message_at;type:datetime;comment:最近接收时间"`
+	LastReadId    int64          `gorm:"column:last_read_id;not null;default:0;comment:已读到的消息自增id"`
+	LastReadUuid  string         `gorm:"column:last_read_uuid;type:char(20);comment:已读到的消息uuid"`
+	LastReadAt    sql.NullTime   `gorm:"column:last_read_at;type:datetime;comment:最近已读时间"`
 	CreatedAt     time.Time      `gorm:"column:created_at;Index;type:datetime;comment:创建时间"`
 	DeletedAt     gorm.DeletedAt `gorm:"column:deleted_at;Index;type:datetime;comment:删除时间"`
 }
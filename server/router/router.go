@@ -4,6 +4,7 @@ import (
 	"Kama-Chat/api"
 	"Kama-Chat/global"
 	"Kama-Chat/middleware"
+	"Kama-Chat/utils/validate"
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,25 +21,38 @@ func init() {
 	//Router.Use(ssl.TlsHandler(global.CONFIG.MainConfig.Host, global.CONFIG.MainConfig.Port))
 	Router.Static("/static/avatars", global.CONFIG.StaticSrcConfig.StaticAvatarPath)
 	Router.Static("/static/files", global.CONFIG.StaticSrcConfig.StaticFilePath)
+	Router.Static("/static/voices", global.CONFIG.StaticSrcConfig.StaticVoicePath)
 	Router.POST("/register", api.UserInfo.Register)
 	Router.POST("/login", api.UserInfo.Login)
+	Router.POST("/user/send_sms_code", api.UserInfo.SendSmsCode)
+	Router.POST("/user/sms_login", api.UserInfo.SmsLogin)
+	// websocket在升级前自行校验token与client_id是否一致
+	Router.GET("/wss", api.Wss.WsLogin)
+
+	// 以下路由都需要携带登录时签发的token
+	privateGp := Router.Group("")
+	privateGp.Use(middleware.JwtAuth())
 
 	// 用户相关
-	userGp := Router.Group("/user")
+	userGp := privateGp.Group("/user")
 	{
 		userGp.POST("/update_user_info", api.UserInfo.UpdateUserInfo)
-		userGp.POST("/get_user_info_list", api.UserInfo.GetUserInfoList)
-		userGp.POST("/able_users", api.UserInfo.AbleUsers)
+		userGp.POST("/update_password", api.UserInfo.UpdatePassword)
 		userGp.POST("/get_user_info", api.UserInfo.GetUserInfo)
-		userGp.POST("/disable_users", api.UserInfo.DisableUsers)
-		userGp.POST("/delete_users", api.UserInfo.DeleteUsers)
-		userGp.POST("/set_admin", api.UserInfo.SetAdmin)
-		userGp.POST("/send_sms_code", api.UserInfo.SendSmsCode)
-		userGp.POST("/sms_login", api.UserInfo.SmsLogin)
+		userGp.POST("/wsLogout", api.Wss.WsLogout)
+	}
+	// 用户管理，仅管理员可用，操作记录审计
+	userAdminGp := userGp.Group("", middleware.AdminAuth())
+	{
+		userAdminGp.POST("/get_user_info_list", middleware.RequirePermission(validate.PermUserList), api.UserInfo.GetUserInfoList)
+		userAdminGp.POST("/able_users", middleware.RequirePermission(validate.PermUserStatus), api.UserInfo.AbleUsers)
+		userAdminGp.POST("/disable_users", middleware.RequirePermission(validate.PermUserStatus), api.UserInfo.DisableUsers)
+		userAdminGp.POST("/delete_users", middleware.RequirePermission(validate.PermUserDelete), api.UserInfo.DeleteUsers)
+		userAdminGp.POST("/set_admin", middleware.RequirePermission(validate.PermUserSetAdmin), api.UserInfo.SetAdmin)
 	}
 
 	// 群组相关
-	groupGp := Router.Group("/group")
+	groupGp := privateGp.Group("/group")
 	{
 		groupGp.POST("/create_group", api.GroupInfo.CreateGroup)
 		groupGp.POST("/load_my_group", api.GroupInfo.LoadMyGroup)
@@ -47,16 +61,24 @@ func init() {
 		groupGp.POST("/leave_group", api.GroupInfo.LeaveGroup)
 		groupGp.POST("/dismiss_group", api.GroupInfo.DismissGroup)
 		groupGp.POST("/get_group_info", api.GroupInfo.GetGroupInfo)
-		groupGp.POST("/get_group_info_list", api.GroupInfo.GetGroupInfoList)
-		groupGp.POST("/delete_groups", api.GroupInfo.DeleteGroups)
-		groupGp.POST("/set_groups_status", api.GroupInfo.SetGroupsStatus)
 		groupGp.POST("/update_group_info", api.GroupInfo.UpdateGroupInfo)
 		groupGp.POST("/get_group_member_list", api.GroupInfo.GetGroupMemberList)
 		groupGp.POST("/remove_group_members", api.GroupInfo.RemoveGroupMembers)
+		groupGp.POST("/set_group_admin", api.GroupInfo.SetGroupAdmin)
+		groupGp.POST("/mute_group_member", api.GroupInfo.MuteGroupMember)
+		groupGp.POST("/set_group_mute_all", api.GroupInfo.SetGroupMuteAll)
+		groupGp.POST("/transfer_group_owner", api.GroupInfo.TransferGroupOwner)
+	}
+	// 群聊管理，仅管理员可用，操作记录审计
+	groupAdminGp := groupGp.Group("", middleware.AdminAuth())
+	{
+		groupAdminGp.POST("/get_group_info_list", middleware.RequirePermission(validate.PermGroupList), api.GroupInfo.GetGroupInfoList)
+		groupAdminGp.POST("/delete_groups", middleware.RequirePermission(validate.PermGroupDelete), api.GroupInfo.DeleteGroups)
+		groupAdminGp.POST("/set_groups_status", middleware.RequirePermission(validate.PermGroupStatus), api.GroupInfo.SetGroupsStatus)
 	}
 
 	// 会话相关
-	sessionGp := Router.Group("/session")
+	sessionGp := privateGp.Group("/session")
 	{
 		sessionGp.POST("/open_session", api.Session.OpenSession)
 		sessionGp.POST("/get_user_session_list", api.Session.GetUserSessionList)
@@ -66,7 +88,7 @@ func init() {
 	}
 
 	// 联系人相关
-	contactGp := Router.Group("/contact")
+	contactGp := privateGp.Group("/contact")
 	{
 		contactGp.POST("/get_user_contact_list", api.UserContact.GetUserContactList)
 		contactGp.POST("/load_my_joined_group", api.UserContact.LoadMyJoinedGroup)
@@ -78,26 +100,35 @@ func init() {
 		contactGp.POST("/black_contact", api.UserContact.BlackContact)
 		contactGp.POST("/cancel_black_contact", api.UserContact.CancelBlackContact)
 		contactGp.POST("/get_add_group_list", api.UserContact.GetAddGroupList)
+		contactGp.POST("/get_group_apply_list", api.UserContact.GetGroupApplyList)
 		contactGp.POST("/refuse_contact_apply", api.UserContact.RefuseContactApply)
 		contactGp.POST("/black_apply", api.UserContact.BlackApply)
 	}
 
 	// 消息相关
-	messageGp := Router.Group("/message")
+	messageGp := privateGp.Group("/message")
 	{
 		messageGp.POST("/get_message_list", api.Message.GetMessageList)
 		messageGp.POST("/get_group_message_list", api.Message.GetGroupMessageList)
+		messageGp.POST("/get_message_readers", api.Message.GetMessageReaders)
+		messageGp.POST("/recall_message", api.Message.RecallMessage)
+		messageGp.POST("/edit_message", api.Message.EditMessage)
+		messageGp.POST("/get_message_edit_history", api.Message.GetMessageEditHistory)
+		messageGp.POST("/delete_message", api.Message.DeleteMessage)
+		messageGp.POST("/pin_message", api.Message.PinMessage)
+		messageGp.POST("/unpin_message", api.Message.UnpinMessage)
+		messageGp.POST("/get_pinned_message_list", api.Message.GetPinnedMessageList)
+		messageGp.POST("/get_thread_message_list", api.Message.GetThreadMessageList)
+		messageGp.POST("/get_unread_mention_list", api.Message.GetUnreadMentionList)
+		messageGp.POST("/search_message", api.Message.SearchMessage)
 		messageGp.POST("/upload_avatar", api.Message.UploadAvatar)
 		messageGp.POST("/upload_file", api.Message.UploadFile)
+		messageGp.POST("/upload_voice", api.Message.UploadVoice)
 	}
 
 	// 聊天室相关
-	chatRoomGp := Router.Group("/chatroom")
+	chatRoomGp := privateGp.Group("/chatroom")
 	{
 		chatRoomGp.POST("/getCurContactListInChatRoom", api.ChatRoom.GetCurContactListInChatRoom)
 	}
-
-	Router.GET("/wss", api.Wss.WsLogin)
-	Router.POST("/user/wsLogout", api.Wss.WsLogout)
-
 }
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"time"
+)
+
+// AdminAuditLogService 管理操作审计服务
+type AdminAuditLogService struct {
+}
+
+// Record 写入一条审计记录
+// 审计失败只记日志，不影响管理操作本身
+func (aals *AdminAuditLogService) Record(auditLog *model.AdminAuditLog) {
+	if auditLog.CreatedAt.IsZero() {
+		auditLog.CreatedAt = time.Now()
+	}
+	if res := dao.GormDB.Create(auditLog); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+}
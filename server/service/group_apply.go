@@ -0,0 +1,121 @@
+package service
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/lib/chat"
+	"Kama-Chat/model"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/validate"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 加群申请由群主和任一管理员处理，先处理的生效，处理人和处理时间记录在申请中
+// 新申请实时推送给群主和管理员，处理结果推送给申请人，并同步给其他群主、管理员的待处理列表
+
+// errApplyHandled 加群申请已经被其他群主或管理员处理
+var errApplyHandled = errors.New("该申请已被处理")
+
+// decideGroupApply 处理一条待处理的加群申请，返回false表示申请已经被其他人处理
+// db 可以是调用方的事务，通过申请时和加入群聊一起提交
+func decideGroupApply(db *gorm.DB, contactApply *model.ContactApply, status int8, operatorId string) (bool, error) {
+	now := time.Now()
+	res := db.Model(&model.ContactApply{}).
+		Where("id = ? AND status = ?", contactApply.Id, enum.PENDING).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"handled_by": operatorId,
+			"handled_at": now,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	contactApply.Status = status
+	contactApply.HandledBy = operatorId
+	contactApply.HandledAt = sql.NullTime{Time: now, Valid: true}
+	return true, nil
+}
+
+// handleGroupApply 拒绝或拉黑加群申请，并把结果推送给申请人
+func handleGroupApply(contactApply *model.ContactApply, status int8, operatorId string) (string, int) {
+	decided, err := decideGroupApply(dao.GormDB, contactApply, status, operatorId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if !decided {
+		return "该申请已被处理", -2
+	}
+	var group model.GroupInfo
+	if res := dao.GormDB.First(&group, "uuid = ?", contactApply.ContactId); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	notifyGroupApplyResult(group, *contactApply)
+	return "", 0
+}
+
+// notifyGroupApply 把新的加群申请推送给群主和管理员
+func notifyGroupApply(group model.GroupInfo, contactApply model.ContactApply, applicant model.UserInfo) {
+	managerIds, _, ret := validate.GetGroupManagerIds(group.Uuid)
+	if ret != 0 {
+		return
+	}
+	messageBack := groupApplyEvent(respond.GroupApplyEventRespond{
+		Event:         enum.EventGroupApply,
+		GroupId:       group.Uuid,
+		GroupName:     group.Name,
+		ContactId:     applicant.Uuid,
+		ContactName:   applicant.Nickname,
+		ContactAvatar: applicant.Avatar,
+		Message:       contactApply.Message,
+		LastApplyAt:   contactApply.LastApplyAt.Format("2006-01-02 15:04:05"),
+	})
+	if messageBack == nil {
+		return
+	}
+	chat.SendToUsers(messageBack, managerIds...)
+}
+
+// notifyGroupApplyResult 把加群申请的处理结果推送给申请人和群主、管理员
+func notifyGroupApplyResult(group model.GroupInfo, contactApply model.ContactApply) {
+	messageBack := groupApplyEvent(respond.GroupApplyResultEventRespond{
+		Event:     enum.EventGroupApplyResult,
+		GroupId:   group.Uuid,
+		GroupName: group.Name,
+		ContactId: contactApply.UserId,
+		Status:    contactApply.Status,
+		HandledBy: contactApply.HandledBy,
+		HandledAt: contactApply.HandledAt.Time.Format("2006-01-02 15:04:05"),
+	})
+	if messageBack == nil {
+		return
+	}
+	receivers := []string{contactApply.UserId}
+	managerIds, _, _ := validate.GetGroupManagerIds(group.Uuid)
+	for _, managerId := range managerIds {
+		if managerId != contactApply.UserId {
+			receivers = append(receivers, managerId)
+		}
+	}
+	chat.SendToUsers(messageBack, receivers...)
+}
+
+// groupApplyEvent 序列化加群申请事件
+func groupApplyEvent(event interface{}) *chat.MessageBack {
+	jsonMessage, err := json.Marshal(event)
+	if err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return &chat.MessageBack{Message: jsonMessage}
+}
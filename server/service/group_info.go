@@ -10,6 +10,8 @@ import (
 	"Kama-Chat/utils/constants"
 	"Kama-Chat/utils/enum"
 	"Kama-Chat/utils/random"
+	"Kama-Chat/utils/validate"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -40,24 +42,22 @@ func (gis *GroupInfoService) CreateGroup(req *request.CreateGroupRequest) (strin
 		UpdatedAt: time.Now(),
 	}
 
-	// 初始化群组成员列表，首先添加群主
-	var members []string
-	members = append(members, req.OwnerId)
-
-	// 将成员列表序列化为JSON格式
-	var err error
-	group.Members, err = json.Marshal(members)
-	if err != nil {
+	// 在数据库中创建群组记录，群主作为第一个成员
+	if err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.GroupMember{
+			GroupId:  group.Uuid,
+			UserId:   req.OwnerId,
+			Role:     enum.GROUP_OWNER,
+			JoinedAt: group.CreatedAt,
+		}).Error
+	}); err != nil {
 		zlog.Error(err.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
 
-	// 在数据库中创建群组记录
-	if res := dao.GormDB.Create(&group); res.Error != nil {
-		zlog.Error(res.Error.Error())
-		return constants.SYSTEM_ERROR, -1
-	}
-
 	// 添加联系人信息，以维护群主和群组的关系
 	contact := model.UserContact{
 		UserId:      req.OwnerId,
@@ -158,31 +158,32 @@ func (gis *GroupInfoService) CheckGroupAddMode(req *request.CheckGroupAddModeReq
 // ownerId 是群聊id
 func (gis *GroupInfoService) EnterGroupDirectly(req *request.EnterGroupDirectlyRequest) (string, int) {
 	var group model.GroupInfo
-	// 查询群组信息
-	if res := dao.GormDB.First(&group, "uuid = ?", req.OwnerId); res.Error != nil {
+	// 查询群组信息，解散的群聊已被软删除，需要包含已删除的记录才能区分解散和不存在
+	if res := dao.GormDB.Unscoped().First(&group, "uuid = ?", req.OwnerId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "群聊不存在", -2
+		}
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
-	var members []string
-	// 将群组成员列表从JSON格式转换为切片
-	if err := json.Unmarshal(group.Members, &members); err != nil {
-		zlog.Error(err.Error())
-		return constants.SYSTEM_ERROR, -1
+	if group.DeletedAt.Valid || group.Status == enum.DISSOLVE {
+		return "群聊已解散", -2
 	}
-	// 将新加入的用户id添加到群组成员列表中
-	members = append(members, req.ContactId)
-	if data, err := json.Marshal(members); err != nil {
+	if group.Status == enum.DISABLE {
+		return "群聊已被禁用", -2
+	}
+	// 需要审核的群聊只能通过加群申请加入
+	if group.AddMode == 1 {
+		return "该群聊需要审核，请发送加群申请", -2
+	}
+	// 将新加入的用户添加为群成员
+	added, err := addGroupMember(dao.GormDB, group.Uuid, req.ContactId, enum.GROUP_MEMBER, "")
+	if err != nil {
 		zlog.Error(err.Error())
 		return constants.SYSTEM_ERROR, -1
-	} else {
-		group.Members = data
 	}
-	// 更新群组成员数量
-	group.MemberCnt += 1
-	// 更新群组信息
-	if res := dao.GormDB.Save(&group); res.Error != nil {
-		zlog.Error(res.Error.Error())
-		return constants.SYSTEM_ERROR, -1
+	if !added {
+		return "你已经在该群聊中", -2
 	}
 	// 创建新的联系人记录
 	newContact := model.UserContact{
@@ -199,29 +200,30 @@ func (gis *GroupInfoService) EnterGroupDirectly(req *request.EnterGroupDirectlyR
 		return constants.SYSTEM_ERROR, -1
 	}
 	// 删除redis群聊信息
-	if err := myredis.DelKeysWithPattern("group_info_" + req.ContactId); err != nil {
+	if err := myredis.DelKeysWithPattern("group_info_" + group.Uuid); err != nil {
 		zlog.Error(err.Error())
 	}
 	// 删除redis群聊成员列表
-	if err := myredis.DelKeysWithPattern("groupmember_list_" + req.ContactId); err != nil {
+	if err := myredis.DelKeysWithPattern("group_member_list_" + group.Uuid); err != nil {
 		zlog.Error(err.Error())
 	}
 	// 删除redis群聊会话列表
-	if err := myredis.DelKeysWithPattern("group_session_list_" + req.OwnerId); err != nil {
+	if err := myredis.DelKeysWithPattern("group_session_list_" + req.ContactId); err != nil {
 		zlog.Error(err.Error())
 	}
 	// 删除redis我的群聊列表
-	if err := myredis.DelKeysWithPattern("my_joined_group_list_" + req.OwnerId); err != nil {
+	if err := myredis.DelKeysWithPattern("my_joined_group_list_" + req.ContactId); err != nil {
 		zlog.Error(err.Error())
 	}
 	// 删除redis session会话
-	if err := myredis.DelKeysWithPattern("session_" + req.OwnerId + "_" + req.ContactId); err != nil {
+	if err := myredis.DelKeysWithPattern("session_" + req.ContactId + "_" + group.Uuid); err != nil {
 		zlog.Error(err.Error())
 	}
 	return "进群成功", 0
 }
 
 // LeaveGroup 退群
+// 群主没有转让就退群时，群主自动转给入群最早的管理员，没有管理员时转给入群最早的成员
 func (gis *GroupInfoService) LeaveGroup(req *request.LeaveGroupRequest) (string, int) {
 	// 从群聊中清除该用户
 	var group model.GroupInfo
@@ -230,30 +232,28 @@ func (gis *GroupInfoService) LeaveGroup(req *request.LeaveGroupRequest) (string,
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
-	var members []string
-	// 将群组成员列表从JSON格式转换为切片
-	if err := json.Unmarshal(group.Members, &members); err != nil {
-		zlog.Error(err.Error())
-		return constants.SYSTEM_ERROR, -1
-	}
-	// 从切片中删除该用户
-	for i, member := range members {
-		if member == req.UserId {
-			members = append(members[:i], members[i+1:]...)
-			break
+	if group.OwnerId == req.UserId {
+		successorId, ok, err := successorOf(group.Uuid, req.UserId)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		if !ok {
+			return "你是群里最后一名成员，请解散群聊", -2
 		}
+		if err := transferGroupOwner(group.Uuid, req.UserId, successorId); err != nil {
+			if errors.Is(err, errOwnerChanged) {
+				return "群主已变更，请重试", -2
+			}
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		sendGroupSystemMessage(group.Uuid, fmt.Sprintf("群主%s退出群聊，%s成为新群主", nicknameOf(req.UserId), nicknameOf(successorId)))
 	}
-	if data, err := json.Marshal(members); err != nil {
+	// 从群成员中删除该用户
+	if err := removeGroupMember(group.Uuid, req.UserId); err != nil {
 		zlog.Error(err.Error())
 		return constants.SYSTEM_ERROR, -1
-	} else {
-		group.Members = data
-	}
-	// 更新群聊成员数量
-	group.MemberCnt -= 1
-	if res := dao.GormDB.Save(&group); res.Error != nil {
-		zlog.Error(res.Error.Error())
-		return constants.SYSTEM_ERROR, -1
 	}
 	// 删除会话
 	var deletedAt gorm.DeletedAt
@@ -290,7 +290,7 @@ func (gis *GroupInfoService) LeaveGroup(req *request.LeaveGroupRequest) (string,
 		zlog.Error(err.Error())
 	}
 	// 删除redis我的群聊列表
-	if err := myredis.DelKeysWithPattern("my_joined_group_list_ " + req.UserId); err != nil {
+	if err := myredis.DelKeysWithPattern("my_joined_group_list_" + req.UserId); err != nil {
 		zlog.Error(err.Error())
 	}
 	// 删除redis session会话
@@ -302,6 +302,10 @@ func (gis *GroupInfoService) LeaveGroup(req *request.LeaveGroupRequest) (string,
 
 // DismissGroup 解散群聊
 func (gis *GroupInfoService) DismissGroup(req *request.DismissGroupRequest) (string, int) {
+	// 只有群主可以解散群聊
+	if _, message, ret := validate.CheckGroupOperator(req.GroupId, req.OwnerId, validate.GroupPermDismiss); ret != 0 {
+		return message, ret
+	}
 	var deletedAt gorm.DeletedAt
 	deletedAt.Time = time.Now()
 	deletedAt.Valid = true
@@ -407,6 +411,7 @@ func (gis *GroupInfoService) GetGroupInfo(req *request.GetGroupInfoRequest) (str
 				MemberCnt: group.MemberCnt,
 				OwnerId:   group.OwnerId,
 				AddMode:   group.AddMode,
+				MuteAll:   group.MuteAll,
 				Status:    group.Status,
 			}
 			if group.DeletedAt.Valid {
@@ -577,6 +582,10 @@ func (gis *GroupInfoService) SetGroupsStatus(req *request.SetGroupsStatusRequest
 
 // UpdateGroupInfo 更新群聊消息
 func (gis *GroupInfoService) UpdateGroupInfo(req *request.UpdateGroupInfoRequest) (string, int) {
+	// 群主和管理员可以修改群资料和群公告
+	if _, message, ret := validate.CheckGroupOperator(req.Uuid, req.OwnerId, validate.GroupPermEditInfo); ret != 0 {
+		return message, ret
+	}
 	var group model.GroupInfo
 	// 查询群聊
 	if res := dao.GormDB.First(&group, "uuid = ?", req.Uuid); res.Error != nil {
@@ -629,28 +638,34 @@ func (gis *GroupInfoService) GetGroupMemberList(req *request.GetGroupMemberListR
 	rspString, err := myredis.GetKeyNilIsErr("group_member_list_" + req.GroupId)
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			var group model.GroupInfo
-			if res := dao.GormDB.First(&group, "uuid = ?", req.GroupId); res.Error != nil {
+			var members []model.GroupMember
+			if res := dao.GormDB.Where("group_id = ?", req.GroupId).Order("joined_at ASC, id ASC").Find(&members); res.Error != nil {
 				zlog.Error(res.Error.Error())
 				return constants.SYSTEM_ERROR, nil, -1
 			}
-			var members []string
-			if err := json.Unmarshal(group.Members, &members); err != nil {
-				zlog.Error(err.Error())
-				return constants.SYSTEM_ERROR, nil, -1
-			}
 			var rspList []respond.GetGroupMemberListRespond
 			for _, member := range members {
 				var user model.UserInfo
-				if res := dao.GormDB.First(&user, "uuid = ?", member); res.Error != nil {
+				if res := dao.GormDB.First(&user, "uuid = ?", member.UserId); res.Error != nil {
 					zlog.Error(res.Error.Error())
 					return constants.SYSTEM_ERROR, nil, -1
 				}
-				rspList = append(rspList, respond.GetGroupMemberListRespond{
-					UserId:   user.Uuid,
-					Nickname: user.Nickname,
-					Avatar:   user.Avatar,
-				})
+				rsp := respond.GetGroupMemberListRespond{
+					UserId:        user.Uuid,
+					Nickname:      user.Nickname,
+					Avatar:        user.Avatar,
+					Role:          member.Role,
+					GroupNickname: member.Nickname,
+					InviterId:     member.InviterId,
+					JoinedAt:      member.JoinedAt.Format("2006-01-02 15:04:05"),
+				}
+				// 禁言以截止时间为准，已到期的禁言顺带清除
+				if validate.IsMuted(member, time.Now()) {
+					rsp.MuteUntil = member.MuteUntil.Time.Format("2006-01-02 15:04:05")
+				} else if member.MuteUntil.Valid {
+					validate.ExpireMute(member)
+				}
+				rspList = append(rspList, rsp)
 			}
 			rspString, err := json.Marshal(rspList)
 			if err != nil {
@@ -670,6 +685,16 @@ func (gis *GroupInfoService) GetGroupMemberList(req *request.GetGroupMemberListR
 	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
 		zlog.Error(err.Error())
 	}
+	// 缓存期间到期的禁言不再返回
+	now := time.Now()
+	for i := range rsp {
+		if rsp[i].MuteUntil == "" {
+			continue
+		}
+		if muteUntil, err := time.ParseInLocation("2006-01-02 15:04:05", rsp[i].MuteUntil, time.Local); err == nil && !muteUntil.After(now) {
+			rsp[i].MuteUntil = ""
+		}
+	}
 	return "获取群聊成员列表成功", rsp, 0
 }
 
@@ -681,10 +706,21 @@ func (gis *GroupInfoService) RemoveGroupMembers(req *request.RemoveGroupMembersR
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
-	var members []string
-	if err := json.Unmarshal(group.Members, &members); err != nil {
-		zlog.Error(err.Error())
-		return constants.SYSTEM_ERROR, -1
+	// 群主和管理员可以移出成员，先检查完所有成员再移除
+	operator, message, ret := validate.CheckGroupOperator(req.GroupId, req.OwnerId, validate.GroupPermKick)
+	if ret != 0 {
+		return message, ret
+	}
+	for _, uuid := range req.UuidList {
+		target, message, ret := validate.GetGroupMember(req.GroupId, uuid)
+		if ret == -2 {
+			return "该用户不在群聊中", -2
+		} else if ret != 0 {
+			return message, ret
+		}
+		if message, ret := validate.CheckCanManageGroupMember(operator, target); ret != 0 {
+			return message, ret
+		}
 	}
 	var deletedAt gorm.DeletedAt
 	deletedAt.Time = time.Now()
@@ -692,16 +728,11 @@ func (gis *GroupInfoService) RemoveGroupMembers(req *request.RemoveGroupMembersR
 	log.Println(req.UuidList, req.OwnerId)
 	// 遍历uuid列表
 	for _, uuid := range req.UuidList {
-		if req.OwnerId == uuid {
-			return "不能移除群主", -2
-		}
-		// 从members中找到uuid，移除
-		for i, member := range members {
-			if member == uuid {
-				members = append(members[:i], members[i+1:]...)
-			}
+		// 从群成员中移除
+		if err := removeGroupMember(group.Uuid, uuid); err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
 		}
-		group.MemberCnt -= 1
 		// 删除会话
 		if res := dao.GormDB.Model(&model.Session{}).Where("send_id = ? AND receive_id = ?", uuid, req.GroupId).Update("deleted_at", deletedAt); res.Error != nil {
 			zlog.Error(res.Error.Error())
@@ -718,12 +749,6 @@ func (gis *GroupInfoService) RemoveGroupMembers(req *request.RemoveGroupMembersR
 			return constants.SYSTEM_ERROR, -1
 		}
 	}
-	group.Members, _ = json.Marshal(members)
-	// 更新群聊
-	if res := dao.GormDB.Save(&group); res.Error != nil {
-		zlog.Error(res.Error.Error())
-		return constants.SYSTEM_ERROR, -1
-	}
 	// 删除redis群聊信息
 	if err := myredis.DelKeysWithPattern("group_info_" + req.GroupId); err != nil {
 		zlog.Error(err.Error())
@@ -742,3 +767,137 @@ func (gis *GroupInfoService) RemoveGroupMembers(req *request.RemoveGroupMembersR
 	}
 	return "移除群聊成员成功", 0
 }
+
+// SetGroupAdmin 设置或取消群管理员，只有群主可以操作
+func (gis *GroupInfoService) SetGroupAdmin(req *request.SetGroupAdminRequest) (string, int) {
+	if _, message, ret := validate.CheckGroupOperator(req.GroupId, req.OwnerId, validate.GroupPermSetAdmin); ret != 0 {
+		return message, ret
+	}
+	target, message, ret := validate.GetGroupMember(req.GroupId, req.UserId)
+	if ret == -2 {
+		return "该用户不在群聊中", -2
+	} else if ret != 0 {
+		return message, ret
+	}
+	if target.Role == enum.GROUP_OWNER {
+		return "不能修改群主的角色", -2
+	}
+	role := int8(enum.GROUP_MEMBER)
+	if req.IsAdmin {
+		role = enum.GROUP_ADMIN
+	}
+	if target.Role == role {
+		if req.IsAdmin {
+			return "该成员已经是管理员", -2
+		}
+		return "该成员不是管理员", -2
+	}
+	if res := dao.GormDB.Model(&model.GroupMember{}).Where("id = ?", target.Id).Update("role", role); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	// 删除redis群聊成员列表
+	if err := myredis.DelKeysWithPattern("group_member_list_" + req.GroupId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if req.IsAdmin {
+		return "设置管理员成功", 0
+	}
+	return "取消管理员成功", 0
+}
+
+// MuteGroupMember 禁言或解除禁言群成员，群主和管理员可以操作，只能禁言角色低于自己的成员
+// 禁言截止时间保存在群成员中，同时把成员的群聊联系人状态改为禁言，到期后自动解除
+func (gis *GroupInfoService) MuteGroupMember(req *request.MuteGroupMemberRequest) (string, int) {
+	if req.Duration < -1 {
+		return "禁言时长不合法", -2
+	}
+	operator, message, ret := validate.CheckGroupOperator(req.GroupId, req.OwnerId, validate.GroupPermMute)
+	if ret != 0 {
+		return message, ret
+	}
+	target, message, ret := validate.GetGroupMember(req.GroupId, req.UserId)
+	if ret == -2 {
+		return "该用户不在群聊中", -2
+	} else if ret != 0 {
+		return message, ret
+	}
+	if message, ret := validate.CheckCanManageGroupMember(operator, target); ret != 0 {
+		return message, ret
+	}
+	var muteUntil sql.NullTime
+	status := int8(enum.NORMAL_)
+	switch {
+	case req.Duration == -1:
+		muteUntil = sql.NullTime{Time: validate.MuteForever, Valid: true}
+		status = enum.SILENCE
+	case req.Duration > 0:
+		muteUntil = sql.NullTime{Time: time.Now().Add(time.Duration(req.Duration) * time.Second), Valid: true}
+		status = enum.SILENCE
+	}
+	if res := dao.GormDB.Model(&model.GroupMember{}).Where("id = ?", target.Id).Update("mute_until", muteUntil); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	// 只修改正常或禁言中的联系人，不覆盖其他状态
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("user_id = ? AND contact_id = ? AND status IN ?", req.UserId, req.GroupId, []int8{enum.NORMAL_, enum.SILENCE}).
+		Updates(map[string]interface{}{
+			"status":    status,
+			"update_at": time.Now(),
+		}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	// 删除redis群聊成员列表
+	if err := myredis.DelKeysWithPattern("group_member_list_" + req.GroupId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if status == enum.NORMAL_ {
+		return "解除禁言成功", 0
+	}
+	return "禁言成功", 0
+}
+
+// SetGroupMuteAll 开启或关闭全员禁言，开启后只有群主和管理员可以发言
+func (gis *GroupInfoService) SetGroupMuteAll(req *request.SetGroupMuteAllRequest) (string, int) {
+	if _, message, ret := validate.CheckGroupOperator(req.GroupId, req.OwnerId, validate.GroupPermMute); ret != 0 {
+		return message, ret
+	}
+	if res := dao.GormDB.Model(&model.GroupInfo{}).Where("uuid = ?", req.GroupId).Update("mute_all", req.MuteAll); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	// 删除redis群聊信息
+	if err := myredis.DelKeysWithPattern("group_info_" + req.GroupId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if req.MuteAll {
+		return "已开启全员禁言", 0
+	}
+	return "已关闭全员禁言", 0
+}
+
+// TransferGroupOwner 转让群主，只有群主可以操作，新群主必须是群成员
+func (gis *GroupInfoService) TransferGroupOwner(req *request.TransferGroupOwnerRequest) (string, int) {
+	if req.NewOwnerId == req.OwnerId {
+		return "不能转让给自己", -2
+	}
+	if _, message, ret := validate.CheckGroupOperator(req.GroupId, req.OwnerId, validate.GroupPermTransfer); ret != 0 {
+		return message, ret
+	}
+	if _, message, ret := validate.GetGroupMember(req.GroupId, req.NewOwnerId); ret == -2 {
+		return "该用户不在群聊中", -2
+	} else if ret != 0 {
+		return message, ret
+	}
+	if err := transferGroupOwner(req.GroupId, req.OwnerId, req.NewOwnerId); err != nil {
+		if errors.Is(err, errOwnerChanged) {
+			return "群主已变更，请重试", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	sendGroupSystemMessage(req.GroupId, fmt.Sprintf("%s已将群主转让给%s", nicknameOf(req.OwnerId), nicknameOf(req.NewOwnerId)))
+	return "转让群主成功", 0
+}
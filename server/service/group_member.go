@@ -0,0 +1,135 @@
+package service
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/lib/chat"
+	myredis "Kama-Chat/lib/redis"
+	"Kama-Chat/model"
+	"Kama-Chat/utils/enum"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// 群成员的增删都在事务里同时维护 group_member 和 group_info.member_cnt
+// 已经在群里时插入会被唯一索引忽略，群人数不会重复增加
+
+// addGroupMember 把用户加入群聊，返回false表示用户已经在群里
+// db 可以是调用方的事务，此时和调用方的其他修改一起提交
+func addGroupMember(db *gorm.DB, groupId string, userId string, role int8, inviterId string) (bool, error) {
+	added := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.GroupMember{
+			GroupId:   groupId,
+			UserId:    userId,
+			Role:      role,
+			InviterId: inviterId,
+			JoinedAt:  time.Now(),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		added = true
+		return tx.Model(&model.GroupInfo{}).Where("uuid = ?", groupId).
+			Update("member_cnt", gorm.Expr("member_cnt + ?", 1)).Error
+	})
+	return added, err
+}
+
+// removeGroupMember 把用户移出群聊，用户不在群里时什么也不做
+func removeGroupMember(groupId string, userId string) error {
+	return dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("group_id = ? AND user_id = ?", groupId, userId).Delete(&model.GroupMember{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		return tx.Model(&model.GroupInfo{}).Where("uuid = ?", groupId).
+			Update("member_cnt", gorm.Expr("member_cnt - ?", 1)).Error
+	})
+}
+
+// errOwnerChanged 转让时群主已经不是原来的用户，例如同时发起了两次转让
+var errOwnerChanged = errors.New("群主已变更")
+
+// transferGroupOwner 把群主从 oldOwnerId 转给 newOwnerId，原群主变为普通成员
+// 群聊的群主、两人的角色和新群主的联系人状态在同一个事务中修改
+func transferGroupOwner(groupId string, oldOwnerId string, newOwnerId string) error {
+	err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.GroupInfo{}).Where("uuid = ? AND owner_id = ?", groupId, oldOwnerId).Update("owner_id", newOwnerId)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errOwnerChanged
+		}
+		if err := tx.Model(&model.GroupMember{}).Where("group_id = ? AND user_id = ?", groupId, oldOwnerId).
+			Update("role", enum.GROUP_MEMBER).Error; err != nil {
+			return err
+		}
+		// 新群主不受禁言限制，同时清除禁言并恢复联系人状态
+		if err := tx.Model(&model.GroupMember{}).Where("group_id = ? AND user_id = ?", groupId, newOwnerId).
+			Updates(map[string]interface{}{"role": enum.GROUP_OWNER, "mute_until": nil}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.UserContact{}).
+			Where("user_id = ? AND contact_id = ? AND status = ?", newOwnerId, groupId, enum.SILENCE).
+			Updates(map[string]interface{}{
+				"status":    enum.NORMAL_,
+				"update_at": time.Now(),
+			}).Error
+	})
+	if err != nil {
+		return err
+	}
+	// 删除redis群聊信息和成员列表
+	if err := myredis.DelKeysWithPattern("group_info_" + groupId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.DelKeysWithPattern("group_member_list_" + groupId); err != nil {
+		zlog.Error(err.Error())
+	}
+	// 删除redis新旧群主的我的群聊列表
+	for _, ownerId := range []string{oldOwnerId, newOwnerId} {
+		if err := myredis.DelKeysWithPattern("contact_mygroup_list_" + ownerId); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	return nil
+}
+
+// successorOf 群主不转让就退群时的继任者，优先选入群最早的管理员，没有管理员时选入群最早的成员
+// 群里没有其他成员时返回false
+func successorOf(groupId string, ownerId string) (string, bool, error) {
+	var successor model.GroupMember
+	res := dao.GormDB.Where("group_id = ? AND user_id != ?", groupId, ownerId).
+		Order("role DESC, joined_at ASC, id ASC").Limit(1).Find(&successor)
+	if res.Error != nil {
+		return "", false, res.Error
+	}
+	return successor.UserId, res.RowsAffected > 0, nil
+}
+
+// nicknameOf 获取用户昵称，用于系统通知，查询失败时使用uuid
+func nicknameOf(uuid string) string {
+	var user model.UserInfo
+	if res := dao.GormDB.Select("nickname").First(&user, "uuid = ?", uuid); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return uuid
+	}
+	return user.Nickname
+}
+
+// sendGroupSystemMessage 在群里发出系统通知，失败只记录日志
+func sendGroupSystemMessage(groupId string, content string) {
+	if err := chat.SendGroupSystemMessage(groupId, content); err != nil {
+		zlog.Error(err.Error())
+	}
+}
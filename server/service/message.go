@@ -4,19 +4,29 @@ import (
 	"Kama-Chat/global"
 	"Kama-Chat/initialize/dao"
 	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/lib/chat"
 	myredis "Kama-Chat/lib/redis"
+	"Kama-Chat/lib/search"
 	"Kama-Chat/model"
 	"Kama-Chat/model/request"
 	"Kama-Chat/model/respond"
 	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/random"
+	"Kama-Chat/utils/validate"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,106 +34,611 @@ type MessageService struct {
 	Ctx *gin.Context
 }
 
-// GetMessageList 获取聊天记录
+// GetMessageList 获取聊天记录，按消息先后排列
+// 不传游标时返回最近一页，前端向上翻页时用本页第一条消息作为before继续获取，返回条数少于limit说明已经到头
+// 最近的消息走redis缓存，更早的消息直接查数据库
 func (ms *MessageService) GetMessageList(req *request.GetMessageListRequest) (string, []respond.GetMessageListRespond, int) {
-	// 获取redis聊天记录
-	rspString, err := myredis.GetKeyNilIsErr("message_list_" + req.UserOneId + "_" + req.UserTwoId)
+	cursor := chat.MessageCursor{Before: req.Before, After: req.After, Around: req.Around, AfterSeq: req.AfterSeq}
+	limit := chat.PageLimit(req.Limit)
+	conversation := func() *gorm.DB {
+		return dao.GormDB.Where("((send_id = ? AND receive_id = ?) OR (send_id = ? AND receive_id = ?))", req.UserOneId, req.UserTwoId, req.UserTwoId, req.UserOneId)
+	}
+	// 获取redis中最近的聊天记录
+	recent, err := loadRecentMessages("message_list_"+req.UserOneId+"_"+req.UserTwoId, conversation, chat.PrivateRespond)
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			zlog.Info(err.Error())
-			zlog.Info(fmt.Sprintf("%s %s", req.UserTwoId, req.UserTwoId))
-			// 从数据库中获取聊天记录
-			var messageList []model.Message
-			if res := dao.GormDB.Where("(send_id = ? AND receive_id = ?) OR (send_id = ? AND receive_id = ?)", req.UserOneId, req.UserTwoId, req.UserTwoId, req.UserOneId).Order("created_at ASC").Find(&messageList); res.Error != nil {
-				zlog.Error(res.Error.Error())
-				return constants.SYSTEM_ERROR, nil, -1
-			}
-			// 获取聊天记录以便存入redis
-			var rspList []respond.GetMessageListRespond
-			for _, message := range messageList {
-				rspList = append(rspList, respond.GetMessageListRespond{
-					SendId:     message.SendId,
-					SendName:   message.SendName,
-					SendAvatar: message.SendAvatar,
-					ReceiveId:  message.ReceiveId,
-					Content:    message.Content,
-					Url:        message.Url,
-					Type:       message.Type,
-					FileType:   message.FileType,
-					FileName:   message.FileName,
-					FileSize:   message.FileSize,
-					CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
-				})
-			}
-			rspString, err := json.Marshal(rspList)
-			if err != nil {
-				zlog.Error(err.Error())
-			}
-			if err := myredis.SetKeyEx("message_list_"+req.UserOneId+"_"+req.UserTwoId, string(rspString), time.Minute*constants.REDIS_TIMEOUT); err != nil {
-				zlog.Error(err.Error())
-			}
-			return "获取聊天记录成功", rspList, 0
-		} else {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, nil, -1
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	uuids := make([]string, 0, len(recent))
+	for _, item := range recent {
+		uuids = append(uuids, item.Uuid)
+	}
+	if start, end, ok := chat.CachedPage(uuids, cursor, limit); ok {
+		return "获取聊天记录成功", personalizeMessages(req.UserOneId, recent[start:end]), 0
+	}
+	// 缓存之外的消息从数据库中获取
+	messageList, message, ret := queryMessagePage(conversation, cursor, limit)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	return "获取聊天记录成功", personalizeMessages(req.UserOneId, messageResponds(messageList, chat.PrivateRespond)), 0
+}
+
+// GetGroupMessageList 获取群聊消息记录，分页方式与 GetMessageList 相同，只有群成员可以查看
+func (ms *MessageService) GetGroupMessageList(req *request.GetGroupMessageListRequest) (string, []respond.GetGroupMessageListRespond, int) {
+	if _, message, ret := validate.GetGroupMember(req.GroupId, req.OwnerId); ret == -2 {
+		return "不在该群聊中，无法查看", nil, -2
+	} else if ret != 0 {
+		return message, nil, ret
+	}
+	cursor := chat.MessageCursor{Before: req.Before, After: req.After, Around: req.Around, AfterSeq: req.AfterSeq}
+	limit := chat.PageLimit(req.Limit)
+	conversation := func() *gorm.DB {
+		return dao.GormDB.Where("receive_id = ?", req.GroupId)
+	}
+	// 获取redis中最近的群聊记录
+	recent, err := loadRecentMessages("group_messagelist_"+req.GroupId, conversation, chat.GroupRespond)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	uuids := make([]string, 0, len(recent))
+	for _, item := range recent {
+		uuids = append(uuids, item.Uuid)
+	}
+	if start, end, ok := chat.CachedPage(uuids, cursor, limit); ok {
+		return "获取聊天记录成功", personalizeGroupMessages(req.OwnerId, recent[start:end]), 0
+	}
+	// 缓存之外的消息从数据库中获取
+	messageList, message, ret := queryMessagePage(conversation, cursor, limit)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	return "获取聊天记录成功", personalizeGroupMessages(req.OwnerId, messageResponds(messageList, chat.GroupRespond)), 0
+}
+
+// GetMessageReaders 获取已读某条消息的成员
+// 成员在该会话上的已读进度不小于这条消息即视为已读，发送者本人不计入
+func (ms *MessageService) GetMessageReaders(req *request.GetMessageReadersRequest) (string, *respond.GetMessageReadersRespond, int) {
+	var message model.Message
+	if res := dao.GormDB.First(&message, "uuid = ?", req.MessageId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "消息不存在", nil, -2
 		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
 	}
-	// 将redis聊天记录返回
-	var rsp []respond.GetMessageListRespond
-	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+	// 只有会话参与者可以查看
+	if msg, ret := checkMessageParticipant(req.OwnerId, message); ret != 0 {
+		return msg, nil, ret
+	}
+	var sessionQuery *gorm.DB
+	if message.ReceiveId[0] == 'G' {
+		sessionQuery = dao.GormDB.Where("receive_id = ? AND send_id != ?", message.ReceiveId, message.SendId)
+	} else {
+		sessionQuery = dao.GormDB.Where("send_id = ? AND receive_id = ?", message.ReceiveId, message.SendId)
+	}
+	var sessionList []model.Session
+	if res := sessionQuery.Where("last_read_id >= ?", message.Id).Order("last_read_at ASC").Find(&sessionList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rsp := &respond.GetMessageReadersRespond{
+		MessageId: message.Uuid,
+		Readers:   make([]respond.MessageReaderRespond, 0, len(sessionList)),
+	}
+	if len(sessionList) == 0 {
+		return "获取成功", rsp, 0
+	}
+	readerIds := make([]string, 0, len(sessionList))
+	for _, session := range sessionList {
+		readerIds = append(readerIds, session.SendId)
+	}
+	var users []model.UserInfo
+	if res := dao.GormDB.Where("uuid IN ?", readerIds).Find(&users); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	userMap := make(map[string]model.UserInfo, len(users))
+	for _, user := range users {
+		userMap[user.Uuid] = user
+	}
+	for _, session := range sessionList {
+		user := userMap[session.SendId]
+		reader := respond.MessageReaderRespond{
+			UserId:   session.SendId,
+			Nickname: user.Nickname,
+			Avatar:   user.Avatar,
+		}
+		if session.LastReadAt.Valid {
+			reader.ReadAt = session.LastReadAt.Time.Format("2006-01-02 15:04:05")
+		}
+		rsp.Readers = append(rsp.Readers, reader)
+	}
+	rsp.ReadCount = len(rsp.Readers)
+	return "获取成功", rsp, 0
+}
+
+// RecallMessage 撤回消息
+// 只能撤回自己在撤回时限内发送的消息，撤回后所有人看到的都是撤回提示
+func (ms *MessageService) RecallMessage(req *request.RecallMessageRequest) (string, int) {
+	message, msg, ret := loadMessage(req.MessageId)
+	if ret != 0 {
+		return msg, ret
+	}
+	if message.SendId != req.OwnerId {
+		return "只能撤回自己发送的消息", -2
+	}
+	if message.Type == enum.AudioOrVideo {
+		return "通话消息不能撤回", -2
+	}
+	if message.RecalledAt.Valid {
+		return "消息已撤回", -2
+	}
+	now := time.Now()
+	if now.Sub(message.CreatedAt) > recallWindow() {
+		return fmt.Sprintf("消息发送超过%d秒，无法撤回", int(recallWindow().Seconds())), -2
+	}
+	err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		// 撤回的消息不保留原内容，编辑历史一并删除
+		if res := tx.Model(&model.Message{}).Where("uuid = ?", message.Uuid).Updates(map[string]interface{}{
+			"content":     constants.RECALLED_TEXT,
+			"url":         "",
+			"file_type":   "",
+			"file_name":   "",
+			"file_size":   "",
+			"duration":    0,
+			"waveform":    "",
+			"recalled_at": sql.NullTime{Time: now, Valid: true},
+		}); res.Error != nil {
+			return res.Error
+		}
+		if res := tx.Where("message_uuid = ?", message.Uuid).Delete(&model.MessageEdit{}); res.Error != nil {
+			return res.Error
+		}
+		// 撤回的消息不再置顶
+		return tx.Where("message_uuid = ?", message.Uuid).Delete(&model.MessagePin{}).Error
+	})
+	if err != nil {
 		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	message.Content = constants.RECALLED_TEXT
+	message.Url, message.FileType, message.FileName, message.FileSize = "", "", "", ""
+	message.Duration, message.Waveform = 0, ""
+	message.RecalledAt = sql.NullTime{Time: now, Valid: true}
+	updateCachedMessage(message)
+	chat.RemoveMessageIndex(message.Uuid)
+	if messageBack := messageEvent(message, enum.EventRecall, now); messageBack != nil {
+		chat.SendToParticipants(message, messageBack)
 	}
-	return "获取群聊记录成功", rsp, 0
+	return "撤回成功", 0
 }
 
-// GetGroupMessageList 获取群聊消息记录
-func (ms *MessageService) GetGroupMessageList(req *request.GetGroupMessageListRequest) (string, []respond.GetGroupMessageListRespond, int) {
-	// 获取redis群聊记录
-	rspString, err := myredis.GetKeyNilIsErr("group_messagelist_" + req.GroupId)
+// EditMessage 编辑文本消息，编辑前的内容记录到编辑历史
+func (ms *MessageService) EditMessage(req *request.EditMessageRequest) (string, int) {
+	if req.Content == "" {
+		return "消息内容不能为空", -2
+	}
+	message, msg, ret := loadMessage(req.MessageId)
+	if ret != 0 {
+		return msg, ret
+	}
+	if message.SendId != req.OwnerId {
+		return "只能编辑自己发送的消息", -2
+	}
+	if message.Type != enum.Text {
+		return "只能编辑文本消息", -2
+	}
+	if message.RecalledAt.Valid {
+		return "消息已撤回，无法编辑", -2
+	}
+	if message.Content == req.Content {
+		return "消息内容没有变化", -2
+	}
+	now := time.Now()
+	err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		edit := model.MessageEdit{
+			MessageUuid: message.Uuid,
+			Content:     message.Content,
+			EditedAt:    now,
+		}
+		if res := tx.Create(&edit); res.Error != nil {
+			return res.Error
+		}
+		// 撤回与编辑同时发生时以撤回为准
+		res := tx.Model(&model.Message{}).Where("uuid = ? AND recalled_at IS NULL", message.Uuid).Updates(map[string]interface{}{
+			"content":   req.Content,
+			"edited_at": sql.NullTime{Time: now, Valid: true},
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			var messageList []model.Message
-			// 从数据库中获取群聊记录
-			if res := dao.GormDB.Where("receive_id = ?", req.GroupId).Order("created_at ASC").Find(&messageList); res.Error != nil {
-				zlog.Error(res.Error.Error())
-				return constants.SYSTEM_ERROR, nil, -1
-			}
-			// 获取群聊记录以便存入redis
-			var rspList []respond.GetGroupMessageListRespond
-			for _, message := range messageList {
-				rsp := respond.GetGroupMessageListRespond{
-					SendId:     message.SendId,
-					SendName:   message.SendName,
-					SendAvatar: message.SendAvatar,
-					ReceiveId:  message.ReceiveId,
-					Content:    message.Content,
-					Url:        message.Url,
-					Type:       message.Type,
-					FileType:   message.FileType,
-					FileName:   message.FileName,
-					FileSize:   message.FileSize,
-					CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
-				}
-				rspList = append(rspList, rsp)
-			}
-			rspString, err := json.Marshal(rspList)
-			if err != nil {
-				zlog.Error(err.Error())
-			}
-			if err := myredis.SetKeyEx("group_messagelist_"+req.GroupId, string(rspString), time.Minute*constants.REDIS_TIMEOUT); err != nil {
-				zlog.Error(err.Error())
-			}
-			return "获取聊天记录成功", rspList, 0
-		} else {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, nil, -1
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "消息已撤回，无法编辑", -2
 		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
 	}
-	var rsp []respond.GetGroupMessageListRespond
-	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+	message.Content = req.Content
+	message.EditedAt = sql.NullTime{Time: now, Valid: true}
+	updateCachedMessage(message)
+	chat.IndexMessage(message)
+	if messageBack := messageEvent(message, enum.EventEdit, now); messageBack != nil {
+		chat.SendToParticipants(message, messageBack)
+	}
+	return "编辑成功", 0
+}
+
+// GetMessageEditHistory 获取消息的编辑历史，按编辑时间先后排列
+func (ms *MessageService) GetMessageEditHistory(req *request.GetMessageEditHistoryRequest) (string, *respond.GetMessageEditHistoryRespond, int) {
+	message, msg, ret := loadMessage(req.MessageId)
+	if ret != 0 {
+		return msg, nil, ret
+	}
+	if msg, ret := checkMessageParticipant(req.OwnerId, message); ret != 0 {
+		return msg, nil, ret
+	}
+	var editList []model.MessageEdit
+	if res := dao.GormDB.Where("message_uuid = ?", message.Uuid).Order("id ASC").Find(&editList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rsp := &respond.GetMessageEditHistoryRespond{
+		MessageId: message.Uuid,
+		Content:   message.Content,
+		History:   make([]respond.MessageEditRespond, 0, len(editList)),
+	}
+	for _, edit := range editList {
+		rsp.History = append(rsp.History, respond.MessageEditRespond{
+			Content:  edit.Content,
+			EditedAt: edit.EditedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return "获取成功", rsp, 0
+}
+
+// DeleteMessage 删除消息，只对自己隐藏，其他参与者仍能看到
+func (ms *MessageService) DeleteMessage(req *request.DeleteMessageRequest) (string, int) {
+	message, msg, ret := loadMessage(req.MessageId)
+	if ret != 0 {
+		return msg, ret
+	}
+	if msg, ret := checkMessageParticipant(req.OwnerId, message); ret != 0 {
+		return msg, ret
+	}
+	now := time.Now()
+	hidden := model.MessageHidden{
+		UserId:      req.OwnerId,
+		MessageUuid: message.Uuid,
+		CreatedAt:   now,
+	}
+	if res := dao.GormDB.Clauses(clause.OnConflict{DoNothing: true}).Create(&hidden); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	// 缓存中的聊天记录不按查看者区分，删除的消息在获取时过滤
+	if messageBack := messageEvent(message, enum.EventDelete, now); messageBack != nil {
+		chat.SendToUser(req.OwnerId, messageBack)
+	}
+	return "删除成功", 0
+}
+
+// PinMessage 置顶群聊消息，需要置顶权限，置顶后推送给全体群成员
+func (ms *MessageService) PinMessage(req *request.PinMessageRequest) (string, int) {
+	message, msg, ret := loadMessage(req.MessageId)
+	if ret != 0 {
+		return msg, ret
+	}
+	if message.ReceiveId[0] != 'G' {
+		return "只有群聊消息可以置顶", -2
+	}
+	if _, msg, ret := validate.CheckGroupOperator(message.ReceiveId, req.OwnerId, validate.GroupPermPin); ret != 0 {
+		return msg, ret
+	}
+	if message.RecalledAt.Valid {
+		return "消息已撤回，无法置顶", -2
+	}
+	now := time.Now()
+	pin := model.MessagePin{
+		GroupId:     message.ReceiveId,
+		MessageUuid: message.Uuid,
+		PinnedBy:    req.OwnerId,
+		CreatedAt:   now,
+	}
+	res := dao.GormDB.Clauses(clause.OnConflict{DoNothing: true}).Create(&pin)
+	if res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if res.RowsAffected == 0 {
+		return "消息已置顶", -2
+	}
+	if messageBack := messageEvent(message, enum.EventPin, now); messageBack != nil {
+		chat.SendToParticipants(message, messageBack)
+	}
+	return "置顶成功", 0
+}
+
+// UnpinMessage 取消置顶群聊消息，需要置顶权限，取消后推送给全体群成员
+func (ms *MessageService) UnpinMessage(req *request.UnpinMessageRequest) (string, int) {
+	message, msg, ret := loadMessage(req.MessageId)
+	if ret != 0 {
+		return msg, ret
+	}
+	if message.ReceiveId[0] != 'G' {
+		return "只有群聊消息可以置顶", -2
+	}
+	if _, msg, ret := validate.CheckGroupOperator(message.ReceiveId, req.OwnerId, validate.GroupPermPin); ret != 0 {
+		return msg, ret
+	}
+	res := dao.GormDB.Where("message_uuid = ?", message.Uuid).Delete(&model.MessagePin{})
+	if res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if res.RowsAffected == 0 {
+		return "消息未置顶", -2
+	}
+	if messageBack := messageEvent(message, enum.EventUnpin, time.Now()); messageBack != nil {
+		chat.SendToParticipants(message, messageBack)
+	}
+	return "取消置顶成功", 0
+}
+
+// GetPinnedMessageList 获取群聊的置顶消息，最近置顶的在前，只有群成员可以查看
+func (ms *MessageService) GetPinnedMessageList(req *request.GetPinnedMessageListRequest) (string, []respond.PinnedMessageRespond, int) {
+	if _, message, ret := validate.GetGroupMember(req.GroupId, req.OwnerId); ret == -2 {
+		return "不在该群聊中，无法查看", nil, -2
+	} else if ret != 0 {
+		return message, nil, ret
+	}
+	var pinList []model.MessagePin
+	if res := dao.GormDB.Where("group_id = ?", req.GroupId).Order("id DESC").Find(&pinList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rspList := make([]respond.PinnedMessageRespond, 0, len(pinList))
+	if len(pinList) == 0 {
+		return "获取成功", rspList, 0
+	}
+	messageIds := make([]string, 0, len(pinList))
+	for _, pin := range pinList {
+		messageIds = append(messageIds, pin.MessageUuid)
+	}
+	var messageList []model.Message
+	if res := dao.GormDB.Where("uuid IN ?", messageIds).Find(&messageList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	messageMap := make(map[string]model.Message, len(messageList))
+	for _, message := range messageList {
+		messageMap[message.Uuid] = message
+	}
+	for _, pin := range pinList {
+		message, ok := messageMap[pin.MessageUuid]
+		if !ok {
+			continue
+		}
+		rspList = append(rspList, respond.PinnedMessageRespond{
+			MessageId: pin.MessageUuid,
+			PinnedBy:  pin.PinnedBy,
+			PinnedAt:  pin.CreatedAt.Format("2006-01-02 15:04:05"),
+			Message:   chat.QuoteOf(message),
+		})
+	}
+	return "获取成功", rspList, 0
+}
+
+// GetThreadMessageList 获取群聊话题，包括根消息和按时间先后排列的全部回复
+// 传入话题中任意一条回复时返回它所在的整个话题
+func (ms *MessageService) GetThreadMessageList(req *request.GetThreadMessageListRequest) (string, *respond.GetThreadMessageListRespond, int) {
+	message, msg, ret := loadMessage(req.MessageId)
+	if ret != 0 {
+		return msg, nil, ret
+	}
+	if message.ReceiveId[0] != 'G' {
+		return "只有群聊消息有话题", nil, -2
+	}
+	if msg, ret := checkMessageParticipant(req.OwnerId, message); ret != 0 {
+		return msg, nil, ret
+	}
+	root := message
+	if message.ThreadId != "" {
+		if root, msg, ret = loadMessage(message.ThreadId); ret != 0 {
+			return msg, nil, ret
+		}
+	}
+	var replyList []model.Message
+	if res := dao.GormDB.Where("thread_id = ?", root.Uuid).Order("id ASC").Find(&replyList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	quotes := loadQuotes(append(replyList, root))
+	hidden := hiddenMessageIds(req.OwnerId)
+	rsp := &respond.GetThreadMessageListRespond{
+		Root:       chat.GroupRespond(root, quotes[root.ReplyTo]),
+		ReplyCount: int64(len(replyList)),
+		Replies:    make([]respond.GetGroupMessageListRespond, 0, len(replyList)),
+	}
+	messageIds := []string{root.Uuid}
+	for _, reply := range replyList {
+		if hidden[reply.Uuid] {
+			continue
+		}
+		rsp.Replies = append(rsp.Replies, chat.GroupRespond(reply, quotes[reply.ReplyTo]))
+		messageIds = append(messageIds, reply.Uuid)
+	}
+	reactions := loadReactions(req.OwnerId, messageIds)
+	rsp.Root.Reactions = reactions[root.Uuid]
+	rsp.Root.Mentioned = isMentioned(req.OwnerId, rsp.Root)
+	for i := range rsp.Replies {
+		rsp.Replies[i].Reactions = reactions[rsp.Replies[i].Uuid]
+		rsp.Replies[i].Mentioned = isMentioned(req.OwnerId, rsp.Replies[i])
+	}
+	return "获取话题成功", rsp, 0
+}
+
+// GetUnreadMentionList 获取用户在各个群聊中未读的@提醒，按时间倒序排列
+// 会话已读进度之后的@才算未读，没有会话的群聊中的@都算未读
+func (ms *MessageService) GetUnreadMentionList(req *request.OwnlistRequest) (string, []respond.UnreadMentionRespond, int) {
+	var mentionList []model.MessageMention
+	if res := dao.GormDB.Table("message_mention AS mm").
+		Select("mm.*").
+		Joins("LEFT JOIN session AS s ON s.send_id = mm.user_id AND s.receive_id = mm.group_id AND s.deleted_at IS NULL").
+		Where("mm.user_id = ? AND mm.message_id > IFNULL(s.last_read_id, 0)", req.OwnerId).
+		Order("mm.message_id DESC").
+		Find(&mentionList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rspList := make([]respond.UnreadMentionRespond, 0, len(mentionList))
+	if len(mentionList) == 0 {
+		return "获取成功", rspList, 0
+	}
+	messageIds := make([]string, 0, len(mentionList))
+	groupIds := make([]string, 0, len(mentionList))
+	for _, mention := range mentionList {
+		messageIds = append(messageIds, mention.MessageUuid)
+		groupIds = append(groupIds, mention.GroupId)
+	}
+	var messageList []model.Message
+	if res := dao.GormDB.Where("uuid IN ?", messageIds).Find(&messageList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	messageMap := make(map[string]model.Message, len(messageList))
+	for _, message := range messageList {
+		messageMap[message.Uuid] = message
+	}
+	var groupList []model.GroupInfo
+	if res := dao.GormDB.Select("uuid", "name").Where("uuid IN ?", groupIds).Find(&groupList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	groupNames := make(map[string]string, len(groupList))
+	for _, group := range groupList {
+		groupNames[group.Uuid] = group.Name
+	}
+	hidden := hiddenMessageIds(req.OwnerId)
+	for _, mention := range mentionList {
+		message, ok := messageMap[mention.MessageUuid]
+		if !ok || hidden[mention.MessageUuid] {
+			continue
+		}
+		rspList = append(rspList, respond.UnreadMentionRespond{
+			GroupId:   mention.GroupId,
+			GroupName: groupNames[mention.GroupId],
+			MessageId: mention.MessageUuid,
+			IsAll:     mention.IsAll,
+			Message:   chat.QuoteOf(message),
+			CreatedAt: message.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return "获取成功", rspList, 0
+}
+
+// SearchMessage 搜索聊天记录，只在自己所在的会话中搜索，按发送时间倒序分页
+// 单聊范围为联系人表中的好友（包括已拉黑、已删除的），群聊范围为仍在其中的群
+func (ms *MessageService) SearchMessage(req *request.SearchMessageRequest) (string, *respond.SearchMessageRespond, int) {
+	terms := search.Terms(req.Keyword)
+	if len(terms) == 0 {
+		return "搜索内容不能为空", nil, -2
+	}
+	if chat.MessageIndexer == nil {
+		zlog.Error("消息搜索索引未初始化")
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	var contactList []model.UserContact
+	if res := dao.GormDB.Select("contact_id", "contact_type", "status").Where("user_id = ?", req.OwnerId).Find(&contactList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	groupIds, message, ret := validate.GetJoinedGroupIds(req.OwnerId)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	joined := make(map[string]bool, len(groupIds))
+	for _, groupId := range groupIds {
+		joined[groupId] = true
+	}
+	conversationIds := make([]string, 0, len(contactList))
+	for _, contact := range contactList {
+		if contact.ContactType == enum.GROUP && !joined[contact.ContactId] {
+			continue
+		}
+		if req.ConversationId == "" || req.ConversationId == contact.ContactId {
+			conversationIds = append(conversationIds, chat.ConversationId(req.OwnerId, contact.ContactId))
+		}
+	}
+	if req.ConversationId != "" && len(conversationIds) == 0 {
+		return "不在该会话中，无法搜索", nil, -2
+	}
+	query := search.Query{
+		UserId:          req.OwnerId,
+		Keyword:         req.Keyword,
+		ConversationIds: conversationIds,
+		SendId:          req.SendId,
+		Type:            req.Type,
+	}
+	var err error
+	if req.StartDate != "" {
+		if query.StartTime, err = time.ParseInLocation("2006-01-02", req.StartDate, time.Local); err != nil {
+			return "日期格式不正确", nil, -2
+		}
+	}
+	if req.EndDate != "" {
+		if query.EndTime, err = time.ParseInLocation("2006-01-02", req.EndDate, time.Local); err != nil {
+			return "日期格式不正确", nil, -2
+		}
+		query.EndTime = query.EndTime.AddDate(0, 0, 1)
+	}
+	query.Limit = chat.PageLimit(req.Limit)
+	query.Offset = (max(req.Page, 1) - 1) * query.Limit
+	uuids, total, err := chat.MessageIndexer.Search(query)
+	if err != nil {
 		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rsp := &respond.SearchMessageRespond{Total: total, Messages: make([]respond.SearchMessageItemRespond, 0, len(uuids))}
+	if len(uuids) == 0 {
+		return "搜索成功", rsp, 0
+	}
+	var messageList []model.Message
+	if res := dao.GormDB.Where("uuid IN ?", uuids).Find(&messageList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
 	}
-	return "获取聊天记录成功", rsp, 0
+	messageMap := make(map[string]model.Message, len(messageList))
+	for _, message := range messageList {
+		messageMap[message.Uuid] = message
+	}
+	// 按索引返回的顺序输出
+	for _, uuid := range uuids {
+		message, ok := messageMap[uuid]
+		if !ok {
+			continue
+		}
+		rsp.Messages = append(rsp.Messages, respond.SearchMessageItemRespond{
+			Uuid:       message.Uuid,
+			SendId:     message.SendId,
+			SendName:   message.SendName,
+			SendAvatar: message.SendAvatar,
+			ReceiveId:  message.ReceiveId,
+			Type:       message.Type,
+			Content:    message.Content,
+			Highlight:  search.Highlight(message.Content, terms),
+			CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+			Seq:        message.Seq,
+		})
+	}
+	return "搜索成功", rsp, 0
 }
 
 // UploadAvatar 上传头像
@@ -202,3 +717,402 @@ func (ms *MessageService) UploadFile(c *gin.Context) (string, int) {
 	}
 	return "上传成功", 0
 }
+
+// voiceFileTypes 允许上传的语音格式
+var voiceFileTypes = map[string]bool{".aac": true, ".amr": true, ".m4a": true, ".mp3": true, ".ogg": true, ".opus": true, ".wav": true, ".webm": true}
+
+// UploadVoice 上传语音，表单字段file为语音文件，duration为时长（秒），waveform为波形的json数组
+// 语音文件重新命名后保存，避免不同用户上传的同名文件互相覆盖
+func (ms *MessageService) UploadVoice(c *gin.Context) (string, *respond.UploadVoiceRespond, int) {
+	file, fileHeader, err := c.Request.FormFile("file")
+	if err != nil {
+		return "缺少语音文件", nil, -2
+	}
+	defer file.Close()
+	if fileHeader.Size > constants.VOICE_MAX_SIZE {
+		return fmt.Sprintf("语音文件不能超过%dMB", constants.VOICE_MAX_SIZE>>20), nil, -2
+	}
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if !voiceFileTypes[ext] {
+		return "不支持的语音格式", nil, -2
+	}
+	duration, err := strconv.Atoi(c.PostForm("duration"))
+	if err != nil {
+		return "语音时长格式不正确", nil, -2
+	}
+	var waveform []int
+	if err := json.Unmarshal([]byte(c.PostForm("waveform")), &waveform); err != nil {
+		return "语音波形格式不正确", nil, -2
+	}
+	if err := chat.ValidateVoice(duration, waveform); err != nil {
+		return err.Error(), nil, -2
+	}
+	fileName := fmt.Sprintf("V%s%s", random.GetNowAndLenRandomString(11), ext)
+	out, err := os.Create(filepath.Join(global.CONFIG.StaticSrcConfig.StaticVoicePath, fileName))
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, file); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	zlog.Info(fmt.Sprintf("完成语音上传：%s，时长%d秒", fileName, duration))
+	return "上传成功", &respond.UploadVoiceRespond{
+		Url:      "/static/voices/" + fileName,
+		FileType: strings.TrimPrefix(ext, "."),
+		FileSize: fmt.Sprintf("%dB", fileHeader.Size),
+		Duration: duration,
+		Waveform: waveform,
+	}, 0
+}
+
+// loadMessage 按uuid获取消息
+func loadMessage(messageId string) (model.Message, string, int) {
+	var message model.Message
+	if res := dao.GormDB.First(&message, "uuid = ?", messageId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return message, "消息不存在", -2
+		}
+		zlog.Error(res.Error.Error())
+		return message, constants.SYSTEM_ERROR, -1
+	}
+	return message, "", 0
+}
+
+// checkMessageParticipant 检查用户是否是消息所在会话的参与者，群聊要求仍在群中
+func checkMessageParticipant(ownerId string, message model.Message) (string, int) {
+	if message.ReceiveId[0] == 'G' {
+		if _, errMessage, ret := validate.GetGroupMember(message.ReceiveId, ownerId); ret == -2 {
+			return "不在该群聊中，无法查看", -2
+		} else if ret != 0 {
+			return errMessage, ret
+		}
+		return "", 0
+	}
+	if ownerId != message.SendId && ownerId != message.ReceiveId {
+		return "无权查看该消息", -2
+	}
+	return "", 0
+}
+
+// recallWindow 允许撤回的时间，未配置时为2分钟
+func recallWindow() time.Duration {
+	if window := global.CONFIG.ChatConfig.RecallWindow; window > 0 {
+		return window * time.Second
+	}
+	return 2 * time.Minute
+}
+
+// updateCachedMessage 把撤回、编辑后的消息同步到redis中的聊天记录，引用它的消息摘要一并更新
+// 缓存中的消息按数据库中的消息重新生成，只保留推送时使用的头像地址
+// 单聊双方各有一份缓存，群聊共用一份
+func updateCachedMessage(message model.Message) {
+	if message.ReceiveId[0] == 'G' {
+		updateCachedMessageList("group_messagelist_"+message.ReceiveId, func(rsp []respond.GetGroupMessageListRespond) []respond.GetGroupMessageListRespond {
+			for i := range rsp {
+				if rsp[i].Uuid == message.Uuid {
+					updated := chat.GroupRespond(message, rsp[i].Quote)
+					updated.SendAvatar = rsp[i].SendAvatar
+					rsp[i] = updated
+				}
+				if rsp[i].ReplyTo == message.Uuid {
+					rsp[i].Quote = chat.QuoteOf(message)
+				}
+			}
+			return rsp
+		})
+		return
+	}
+	update := func(rsp []respond.GetMessageListRespond) []respond.GetMessageListRespond {
+		for i := range rsp {
+			if rsp[i].Uuid == message.Uuid {
+				updated := chat.PrivateRespond(message, rsp[i].Quote)
+				updated.SendAvatar = rsp[i].SendAvatar
+				rsp[i] = updated
+			}
+			if rsp[i].ReplyTo == message.Uuid {
+				rsp[i].Quote = chat.QuoteOf(message)
+			}
+		}
+		return rsp
+	}
+	updateCachedMessageList("message_list_"+message.SendId+"_"+message.ReceiveId, update)
+	updateCachedMessageList("message_list_"+message.ReceiveId+"_"+message.SendId, update)
+}
+
+// updateCachedMessageList 修改redis中缓存的聊天记录，没有缓存时不处理，单聊和群聊的缓存格式不同
+func updateCachedMessageList[T any](key string, update func([]T) []T) {
+	rspString, err := myredis.GetKeyNilIsErr(key)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			zlog.Error(err.Error())
+		}
+		return
+	}
+	var rsp []T
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	rspByte, err := json.Marshal(update(rsp))
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if err := myredis.SetKeyEx(key, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// hiddenMessageIds 获取用户删除过的消息
+func hiddenMessageIds(userId string) map[string]bool {
+	var messageIds []string
+	if res := dao.GormDB.Model(&model.MessageHidden{}).Where("user_id = ?", userId).Pluck("message_uuid", &messageIds); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	hidden := make(map[string]bool, len(messageIds))
+	for _, messageId := range messageIds {
+		hidden[messageId] = true
+	}
+	return hidden
+}
+
+// personalizeMessages 按查看者处理单聊记录，去掉自己删除的消息并汇总表情回应
+func personalizeMessages(userId string, rsp []respond.GetMessageListRespond) []respond.GetMessageListRespond {
+	rsp = filterHiddenMessages(userId, rsp, func(item respond.GetMessageListRespond) string { return item.Uuid })
+	messageIds := make([]string, 0, len(rsp))
+	for _, item := range rsp {
+		messageIds = append(messageIds, item.Uuid)
+	}
+	reactions := loadReactions(userId, messageIds)
+	for i := range rsp {
+		rsp[i].Reactions = reactions[rsp[i].Uuid]
+	}
+	return rsp
+}
+
+// personalizeGroupMessages 按查看者处理群聊记录，去掉自己删除的消息，汇总表情回应并标记@到自己的消息
+func personalizeGroupMessages(userId string, rsp []respond.GetGroupMessageListRespond) []respond.GetGroupMessageListRespond {
+	rsp = filterHiddenMessages(userId, rsp, func(item respond.GetGroupMessageListRespond) string { return item.Uuid })
+	messageIds := make([]string, 0, len(rsp))
+	for _, item := range rsp {
+		messageIds = append(messageIds, item.Uuid)
+	}
+	reactions := loadReactions(userId, messageIds)
+	for i := range rsp {
+		rsp[i].Reactions = reactions[rsp[i].Uuid]
+		rsp[i].Mentioned = isMentioned(userId, rsp[i])
+	}
+	return rsp
+}
+
+// isMentioned 判断群聊消息是否@到了该用户，自己发的@所有人不算
+func isMentioned(userId string, rsp respond.GetGroupMessageListRespond) bool {
+	if rsp.SendId == userId {
+		return false
+	}
+	if rsp.MentionAll {
+		return true
+	}
+	for _, mention := range rsp.Mentions {
+		if mention == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// loadReactions 汇总消息的表情回应，以消息uuid为键，同一消息的表情按首次回应的先后排列
+func loadReactions(userId string, messageIds []string) map[string][]respond.ReactionRespond {
+	reactions := make(map[string][]respond.ReactionRespond)
+	if len(messageIds) == 0 {
+		return reactions
+	}
+	var rows []struct {
+		MessageUuid string
+		Emoji       string
+		Count       int64
+		Reacted     bool
+	}
+	if res := dao.GormDB.Model(&model.MessageReaction{}).
+		Select("message_uuid, emoji, COUNT(*) AS count, MAX(user_id = ?) AS reacted", userId).
+		Where("message_uuid IN ?", messageIds).
+		Group("message_uuid, emoji").
+		Order("MIN(id) ASC").
+		Scan(&rows); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return reactions
+	}
+	for _, row := range rows {
+		reactions[row.MessageUuid] = append(reactions[row.MessageUuid], respond.ReactionRespond{
+			Emoji:   row.Emoji,
+			Count:   row.Count,
+			Reacted: row.Reacted,
+		})
+	}
+	return reactions
+}
+
+// filterHiddenMessages 去掉用户自己删除的消息，uuidOf 取出单聊或群聊消息的uuid
+func filterHiddenMessages[T any](userId string, rsp []T, uuidOf func(T) string) []T {
+	hidden := hiddenMessageIds(userId)
+	if len(hidden) == 0 {
+		return rsp
+	}
+	var kept []T
+	for _, item := range rsp {
+		if !hidden[uuidOf(item)] {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// messageEvent 构造消息变更事件
+func messageEvent(message model.Message, event string, updatedAt time.Time) *chat.MessageBack {
+	jsonMessage, err := json.Marshal(respond.MessageEventRespond{
+		Event:     event,
+		MessageId: message.Uuid,
+		SendId:    message.SendId,
+		ReceiveId: message.ReceiveId,
+		Content:   message.Content,
+		UpdatedAt: updatedAt.Format("2006-01-02 15:04:05"),
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return &chat.MessageBack{Message: jsonMessage}
+}
+
+// loadQuotes 批量获取消息引用的消息摘要，以被引用消息的uuid为键
+func loadQuotes(messageList []model.Message) map[string]*respond.QuotedMessageRespond {
+	quotes := make(map[string]*respond.QuotedMessageRespond)
+	var replyTo []string
+	for _, message := range messageList {
+		if message.ReplyTo != "" {
+			replyTo = append(replyTo, message.ReplyTo)
+		}
+	}
+	if len(replyTo) == 0 {
+		return quotes
+	}
+	var quotedList []model.Message
+	if res := dao.GormDB.Where("uuid IN ?", replyTo).Find(&quotedList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return quotes
+	}
+	for _, quoted := range quotedList {
+		quotes[quoted.Uuid] = chat.QuoteOf(quoted)
+	}
+	return quotes
+}
+
+// queryMessagePage 从数据库中获取一页聊天记录，按消息先后排列
+// conversation 每次调用都返回限定在该会话内的新查询
+func queryMessagePage(conversation func() *gorm.DB, cursor chat.MessageCursor, limit int) ([]model.Message, string, int) {
+	if cursor.AfterSeq > 0 {
+		var messageList []model.Message
+		if res := conversation().Where("seq > ?", cursor.AfterSeq).Order("seq ASC").Limit(limit).Find(&messageList); res.Error != nil {
+			zlog.Error(res.Error.Error())
+			return nil, constants.SYSTEM_ERROR, -1
+		}
+		return messageList, "", 0
+	}
+	var target model.Message
+	if uuid := cursor.Target(); uuid != "" {
+		if res := conversation().Where("uuid = ?", uuid).First(&target); res.Error != nil {
+			if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+				return nil, "消息不存在", -2
+			}
+			zlog.Error(res.Error.Error())
+			return nil, constants.SYSTEM_ERROR, -1
+		}
+	}
+	// older 获取某条消息之前的若干条，按先后排列
+	older := func(id int64, count int) ([]model.Message, error) {
+		var messageList []model.Message
+		query := conversation()
+		if id > 0 {
+			query = query.Where("id < ?", id)
+		}
+		if res := query.Order("id DESC").Limit(count).Find(&messageList); res.Error != nil {
+			return nil, res.Error
+		}
+		for i, j := 0, len(messageList)-1; i < j; i, j = i+1, j-1 {
+			messageList[i], messageList[j] = messageList[j], messageList[i]
+		}
+		return messageList, nil
+	}
+	// newer 获取某条消息之后的若干条，按先后排列
+	newer := func(id int64, count int) ([]model.Message, error) {
+		var messageList []model.Message
+		if res := conversation().Where("id > ?", id).Order("id ASC").Limit(count).Find(&messageList); res.Error != nil {
+			return nil, res.Error
+		}
+		return messageList, nil
+	}
+	var messageList []model.Message
+	var err error
+	switch {
+	case cursor.After != "":
+		messageList, err = newer(target.Id, limit)
+	case cursor.Before != "":
+		messageList, err = older(target.Id, limit)
+	case cursor.Around != "":
+		before := limit / 2
+		var after []model.Message
+		if messageList, err = older(target.Id, before); err == nil {
+			after, err = newer(target.Id, limit-before-1)
+			messageList = append(append(messageList, target), after...)
+		}
+	default:
+		messageList, err = older(0, limit)
+	}
+	if err != nil {
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	return messageList, "", 0
+}
+
+// loadRecentMessages 获取redis中缓存的最近消息，没有缓存时从数据库加载并写入缓存
+// build 为 chat.PrivateRespond 或 chat.GroupRespond，与缓存的格式一致
+func loadRecentMessages[T any](key string, conversation func() *gorm.DB, build func(model.Message, *respond.QuotedMessageRespond) T) ([]T, error) {
+	rspString, err := myredis.GetKeyNilIsErr(key)
+	if err == nil {
+		var rsp []T
+		if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+			return nil, err
+		}
+		return rsp, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	messageList, _, ret := queryMessagePage(conversation, chat.MessageCursor{}, constants.MESSAGE_CACHE_SIZE)
+	if ret != 0 {
+		return nil, errors.New("获取最近的聊天记录失败")
+	}
+	rspList := messageResponds(messageList, build)
+	rspByte, err := json.Marshal(rspList)
+	if err != nil {
+		return nil, err
+	}
+	if err := myredis.SetKeyEx(key, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
+		zlog.Error(err.Error())
+	}
+	return rspList, nil
+}
+
+// messageResponds 把数据库中的消息转换为返回给前端的格式，引用的消息摘要批量获取
+func messageResponds[T any](messageList []model.Message, build func(model.Message, *respond.QuotedMessageRespond) T) []T {
+	quotes := loadQuotes(messageList)
+	rspList := make([]T, 0, len(messageList))
+	for _, message := range messageList {
+		rspList = append(rspList, build(message, quotes[message.ReplyTo]))
+	}
+	return rspList
+}
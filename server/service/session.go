@@ -37,6 +37,18 @@ func (ss *SessionService) CreateSession(req *request.CreateSessionRequest) (stri
 	session.SendId = req.SendId
 	session.ReceiveId = req.ReceiveId
 	session.CreatedAt = time.Now()
+	// 新建会话时已有的消息视为已读，避免删除会话后重新打开时历史消息全部变成未读
+	var lastMessage model.Message
+	lastMessageQuery := dao.GormDB.Where("receive_id = ?", req.ReceiveId)
+	if req.ReceiveId[0] == 'U' {
+		lastMessageQuery = dao.GormDB.Where("(send_id = ? AND receive_id = ?) OR (send_id = ? AND receive_id = ?)", req.SendId, req.ReceiveId, req.ReceiveId, req.SendId)
+	}
+	if res := lastMessageQuery.Order("id DESC").Limit(1).Find(&lastMessage); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	session.LastReadId = lastMessage.Id
+	session.LastReadUuid = lastMessage.Uuid
 	// req.ReceiveId[0] == 'U' 代表是用户对话
 	if req.ReceiveId[0] == 'U' {
 		var receiveUser model.UserInfo
@@ -204,6 +216,11 @@ func (ss *SessionService) GetUserSessionList(req *request.OwnlistRequest) (strin
 			if err := myredis.SetKeyEx("session_list_"+req.OwnerId, string(rspString), time.Minute*constants.REDIS_TIMEOUT); err != nil {
 				zlog.Error(err.Error())
 			}
+			// 未读数变化频繁，不走缓存
+			if err := fillUserSessionUnread(req.OwnerId, sessionListRsp); err != nil {
+				zlog.Error(err.Error())
+				return constants.SYSTEM_ERROR, nil, -1
+			}
 			return "获取成功", sessionListRsp, 0
 		} else {
 			zlog.Error(err.Error())
@@ -215,6 +232,10 @@ func (ss *SessionService) GetUserSessionList(req *request.OwnlistRequest) (strin
 	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
 		zlog.Error(err.Error())
 	}
+	if err := fillUserSessionUnread(req.OwnerId, rsp); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
 	return "获取成功", rsp, 0
 }
 
@@ -255,6 +276,11 @@ func (ss *SessionService) GetGroupSessionList(req *request.OwnlistRequest) (stri
 			if err := myredis.SetKeyEx("group_session_list_"+req.OwnerId, string(rspString), time.Minute*constants.REDIS_TIMEOUT); err != nil {
 				zlog.Error(err.Error())
 			}
+			// 未读数变化频繁，不走缓存
+			if err := fillGroupSessionUnread(req.OwnerId, sessionListRsp); err != nil {
+				zlog.Error(err.Error())
+				return constants.SYSTEM_ERROR, nil, -1
+			}
 			return "获取成功", sessionListRsp, 0
 		} else {
 			zlog.Error(err.Error())
@@ -266,6 +292,10 @@ func (ss *SessionService) GetGroupSessionList(req *request.OwnlistRequest) (stri
 	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
 		zlog.Error(err.Error())
 	}
+	if err := fillGroupSessionUnread(req.OwnerId, rsp); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
 	return "获取成功", rsp, 0
 }
 
@@ -297,3 +327,57 @@ func (ss *SessionService) DeleteSession(req *request.DeleteSessionRequest) (stri
 	}
 	return "删除成功", 0
 }
+
+// loadReadState 获取用户所有会话的已读进度，以会话uuid为键
+func loadReadState(ownerId string) (map[string]model.Session, error) {
+	var sessionList []model.Session
+	if res := dao.GormDB.Select("uuid", "receive_id", "last_read_id", "last_read_uuid").Where("send_id = ?", ownerId).Find(&sessionList); res.Error != nil {
+		return nil, res.Error
+	}
+	readState := make(map[string]model.Session, len(sessionList))
+	for _, session := range sessionList {
+		readState[session.Uuid] = session
+	}
+	return readState, nil
+}
+
+// fillUserSessionUnread 填充单聊会话的未读数和已读位置，未读数为对方发来的、已读进度之后的消息数
+func fillUserSessionUnread(ownerId string, rsp []respond.UserSessionListRespond) error {
+	readState, err := loadReadState(ownerId)
+	if err != nil {
+		return err
+	}
+	for i := range rsp {
+		session := readState[rsp[i].SessionId]
+		if res := dao.GormDB.Model(&model.Message{}).
+			Where("send_id = ? AND receive_id = ? AND id > ?", rsp[i].UserId, ownerId, session.LastReadId).
+			Count(&rsp[i].UnreadCount); res.Error != nil {
+			return res.Error
+		}
+		rsp[i].LastReadMessageId = session.LastReadUuid
+	}
+	return nil
+}
+
+// fillGroupSessionUnread 填充群聊会话的未读数、@计数和已读位置，未读数为其他成员发送的、已读进度之后的消息数
+func fillGroupSessionUnread(ownerId string, rsp []respond.GroupSessionListRespond) error {
+	readState, err := loadReadState(ownerId)
+	if err != nil {
+		return err
+	}
+	for i := range rsp {
+		session := readState[rsp[i].SessionId]
+		if res := dao.GormDB.Model(&model.Message{}).
+			Where("receive_id = ? AND send_id != ? AND id > ?", rsp[i].GroupId, ownerId, session.LastReadId).
+			Count(&rsp[i].UnreadCount); res.Error != nil {
+			return res.Error
+		}
+		if res := dao.GormDB.Model(&model.MessageMention{}).
+			Where("user_id = ? AND group_id = ? AND message_id > ?", ownerId, rsp[i].GroupId, session.LastReadId).
+			Count(&rsp[i].MentionCount); res.Error != nil {
+			return res.Error
+		}
+		rsp[i].LastReadMessageId = session.LastReadUuid
+	}
+	return nil
+}
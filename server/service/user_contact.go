@@ -10,6 +10,8 @@ import (
 	"Kama-Chat/utils/constants"
 	"Kama-Chat/utils/enum"
 	"Kama-Chat/utils/random"
+	"Kama-Chat/utils/validate"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -163,15 +165,34 @@ func (ucs *UserContactService) GetContactInfo(req *request.GetContactInfoRequest
 		}
 		// 判断群聊状态
 		if group.Status != enum.DISABLE {
+			memberIds, message, ret := validate.GetGroupMemberIds(group.Uuid)
+			if ret != 0 {
+				return message, respond.GetContactInfoRespond{}, ret
+			}
+			members, err := json.Marshal(memberIds)
+			if err != nil {
+				zlog.Error(err.Error())
+				return constants.SYSTEM_ERROR, respond.GetContactInfoRespond{}, -1
+			}
+			// 调用方的禁言状态以禁言截止时间为准，已到期的禁言顺带清除
+			var muteUntil string
+			if member, _, ret := validate.GetGroupMember(group.Uuid, req.OwnerId); ret == 0 {
+				if validate.IsMuted(member, time.Now()) {
+					muteUntil = member.MuteUntil.Time.Format("2006-01-02 15:04:05")
+				} else if member.MuteUntil.Valid {
+					validate.ExpireMute(member)
+				}
+			}
 			return "获取联系人信息成功", respond.GetContactInfoRespond{
 				ContactId:        group.Uuid,
 				ContactName:      group.Name,
 				ContactAvatar:    group.Avatar,
 				ContactNotice:    group.Notice,
 				ContactAddMode:   group.AddMode,
-				ContactMembers:   group.Members,
+				ContactMembers:   members,
 				ContactMemberCnt: group.MemberCnt,
 				ContactOwnerId:   group.OwnerId,
+				ContactMuteUntil: muteUntil,
 			}, 0
 		} else {
 			zlog.Error("该群聊处于禁用状态")
@@ -325,6 +346,11 @@ func (ucs *UserContactService) ApplyContact(req *request.ApplyContactRequest) (s
 			zlog.Info("群聊已被禁用")
 			return "群聊已被禁用", -2
 		}
+		if _, message, ret := validate.GetGroupMember(group.Uuid, req.OwnerId); ret == 0 {
+			return "你已经在该群聊中", -2
+		} else if ret != -2 {
+			return message, ret
+		}
 		// 判断是否存在申请记录
 		var contactApply model.ContactApply
 		if res := dao.GormDB.Where("user_id = ? AND contact_id = ?", req.OwnerId, req.ContactId).First(&contactApply); res.Error != nil {
@@ -348,12 +374,26 @@ func (ucs *UserContactService) ApplyContact(req *request.ApplyContactRequest) (s
 				return constants.SYSTEM_ERROR, -1
 			}
 		}
-		contactApply.LastApplyAt = time.Now()
 		// 如果存在申请记录，先看看有没有被拉黑
+		if contactApply.Status == enum.BLACK_ {
+			return "该群聊已拒绝你的申请", -2
+		}
+		// 重新申请时回到待处理，清除上一次的处理人
+		contactApply.LastApplyAt = time.Now()
+		contactApply.Status = enum.PENDING
+		contactApply.Message = req.Message
+		contactApply.HandledBy = ""
+		contactApply.HandledAt = sql.NullTime{}
 		if res := dao.GormDB.Save(&contactApply); res.Error != nil {
 			zlog.Error(res.Error.Error())
 			return constants.SYSTEM_ERROR, -1
 		}
+		var applicant model.UserInfo
+		if res := dao.GormDB.First(&applicant, "uuid = ?", req.OwnerId); res.Error != nil {
+			zlog.Error(res.Error.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		notifyGroupApply(group, contactApply, applicant)
 		return "申请成功", 0
 	} else {
 		return "用户/群聊不存在", -2
@@ -404,9 +444,19 @@ func (ucs *UserContactService) GetNewContactList(req *request.OwnlistRequest) (s
 // PassContactApply 通过联系人申请
 func (ucs *UserContactService) PassContactApply(req *request.PassContactApplyRequest) (string, int) {
 	// ownerId 如果是用户的话就是登录用户，如果是群聊的话就是群聊id
+	// 加群申请只有群主和管理员可以处理
+	if req.OwnerId[0] == 'G' {
+		if _, message, ret := validate.CheckGroupOperator(req.OwnerId, req.OperatorId, validate.GroupPermApproveJoin); ret != 0 {
+			return message, ret
+		}
+	}
 	var contactApply model.ContactApply
 	// 查询申请记录
+	// 申请必须是发给调用方（或调用方管理的群聊）的，否则视为不存在
 	if res := dao.GormDB.Where("contact_id = ? AND user_id = ?", req.OwnerId, req.ContactId).First(&contactApply); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "申请不存在", -2
+		}
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
@@ -468,43 +518,48 @@ func (ucs *UserContactService) PassContactApply(req *request.PassContactApplyReq
 			zlog.Error("群聊已被禁用")
 			return "群聊已被禁用", -2
 		}
-		contactApply.Status = enum.AGREE
-		// 更新申请记录
-		if res := dao.GormDB.Save(&contactApply); res.Error != nil {
-			zlog.Error(res.Error.Error())
-			return constants.SYSTEM_ERROR, -1
-		}
-		// 群聊就只用创建一个UserContact，因为一个UserContact足以表达双方的状态
-		newContact := model.UserContact{
-			UserId:      req.ContactId,
-			ContactId:   req.OwnerId,
-			ContactType: enum.GROUP,  // 用户
-			Status:      enum.NORMAL, // 正常
-			CreatedAt:   time.Now(),
-			UpdateAt:    time.Now(),
-		}
-		// 创建联系人记录
-		if res := dao.GormDB.Create(&newContact); res.Error != nil {
-			zlog.Error(res.Error.Error())
+		// 处理申请、创建联系人和加入群聊在同一个事务中，任何一步失败申请都保持待处理，其他群主或管理员可以重试
+		if err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+			// 更新申请记录，其他群主或管理员已经处理过时不再重复处理
+			if decided, err := decideGroupApply(tx, &contactApply, enum.AGREE, req.OperatorId); err != nil {
+				return err
+			} else if !decided {
+				return errApplyHandled
+			}
+			// 群聊就只用创建一个UserContact，因为一个UserContact足以表达双方的状态
+			newContact := model.UserContact{
+				UserId:      req.ContactId,
+				ContactId:   req.OwnerId,
+				ContactType: enum.GROUP,  // 用户
+				Status:      enum.NORMAL, // 正常
+				CreatedAt:   time.Now(),
+				UpdateAt:    time.Now(),
+			}
+			// 创建联系人记录
+			if err := tx.Create(&newContact).Error; err != nil {
+				return err
+			}
+			// 向群聊中添加该用户
+			_, err := addGroupMember(tx, req.OwnerId, req.ContactId, enum.GROUP_MEMBER, "")
+			return err
+		}); err != nil {
+			if errors.Is(err, errApplyHandled) {
+				return err.Error(), -2
+			}
+			zlog.Error(err.Error())
 			return constants.SYSTEM_ERROR, -1
 		}
-		var members []string
-		if err := json.Unmarshal(group.Members, &members); err != nil {
+		// 删除redis缓存
+		if err := myredis.DelKeysWithPattern("my_joined_group_list_" + req.ContactId); err != nil {
 			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
 		}
-		// 向群聊中添加该用户
-		members = append(members, req.ContactId)
-		group.MemberCnt = len(members)
-		group.Members, _ = json.Marshal(members)
-		if res := dao.GormDB.Save(&group); res.Error != nil {
-			zlog.Error(res.Error.Error())
-			return constants.SYSTEM_ERROR, -1
+		if err := myredis.DelKeysWithPattern("group_member_list_" + req.OwnerId); err != nil {
+			zlog.Error(err.Error())
 		}
-		// 删除redis缓存
-		if err := myredis.DelKeysWithPattern("my_joined_group_list_" + req.OwnerId); err != nil {
+		if err := myredis.DelKeysWithPattern("group_info_" + req.OwnerId); err != nil {
 			zlog.Error(err.Error())
 		}
+		notifyGroupApplyResult(group, contactApply)
 		return "已通过加群申请", 0
 	}
 }
@@ -512,23 +567,35 @@ func (ucs *UserContactService) PassContactApply(req *request.PassContactApplyReq
 // RefuseContactApply 拒绝联系人申请
 func (ucs *UserContactService) RefuseContactApply(req *request.PassContactApplyRequest) (string, int) {
 	// ownerId 如果是用户的话就是登录用户，如果是群聊的话就是群聊id
+	// 加群申请只有群主和管理员可以处理
+	if req.OwnerId[0] == 'G' {
+		if _, message, ret := validate.CheckGroupOperator(req.OwnerId, req.OperatorId, validate.GroupPermApproveJoin); ret != 0 {
+			return message, ret
+		}
+	}
 	var contactApply model.ContactApply
 	// 查询申请记录
+	// 申请必须是发给调用方（或调用方管理的群聊）的，否则视为不存在
 	if res := dao.GormDB.Where("contact_id = ? AND user_id = ?", req.OwnerId, req.ContactId).First(&contactApply); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "申请不存在", -2
+		}
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	if req.OwnerId[0] == 'G' {
+		if message, ret := handleGroupApply(&contactApply, enum.REFUSE, req.OperatorId); ret != 0 {
+			return message, ret
+		}
+		return "已拒绝该加群申请", 0
+	}
 	// 更新申请记录（拒绝）
 	contactApply.Status = enum.REFUSE
 	if res := dao.GormDB.Save(&contactApply); res.Error != nil {
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
-	if req.OwnerId[0] == 'U' {
-		return "已拒绝该联系人申请", 0
-	} else {
-		return "已拒绝该加群申请", 0
-	}
+	return "已拒绝该联系人申请", 0
 
 }
 
@@ -596,8 +663,11 @@ func (ucs *UserContactService) CancelBlackContact(req *request.BlackContactReque
 }
 
 // GetAddGroupList 获取新的加群列表
-// 前端已经判断调用接口的用户是群主，也只有群主才能调用这个接口
+// 群主和管理员都可以查看
 func (ucs *UserContactService) GetAddGroupList(req *request.AddGroupListRequest) (string, []respond.AddGroupListRespond, int) {
+	if _, message, ret := validate.CheckGroupOperator(req.GroupId, req.OwnerId, validate.GroupPermApproveJoin); ret != 0 {
+		return message, nil, ret
+	}
 	var contactApplyList []model.ContactApply
 	// 查询申请记录
 	if res := dao.GormDB.Where("contact_id = ? AND status = ?", req.GroupId, enum.PENDING).Find(&contactApplyList); res.Error != nil {
@@ -635,14 +705,81 @@ func (ucs *UserContactService) GetAddGroupList(req *request.AddGroupListRequest)
 	return "获取成功", rsp, 0
 }
 
+// GetGroupApplyList 获取我作为群主或管理员的所有群聊中待处理的加群申请，最近申请的在前
+func (ucs *UserContactService) GetGroupApplyList(req *request.OwnlistRequest) (string, []respond.GroupApplyListRespond, int) {
+	var groupIds []string
+	if res := dao.GormDB.Model(&model.GroupMember{}).Where("user_id = ? AND role >= ?", req.OwnerId, enum.GROUP_ADMIN).
+		Pluck("group_id", &groupIds); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if len(groupIds) == 0 {
+		return "获取成功", nil, 0
+	}
+	var contactApplyList []model.ContactApply
+	if res := dao.GormDB.Where("contact_id IN ? AND status = ?", groupIds, enum.PENDING).
+		Order("last_apply_at DESC").Find(&contactApplyList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	var groupList []model.GroupInfo
+	if res := dao.GormDB.Where("uuid IN ?", groupIds).Find(&groupList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	groupNames := make(map[string]string, len(groupList))
+	for _, group := range groupList {
+		groupNames[group.Uuid] = group.Name
+	}
+	var rsp []respond.GroupApplyListRespond
+	for _, contactApply := range contactApplyList {
+		// 已解散的群聊不再显示申请
+		groupName, ok := groupNames[contactApply.ContactId]
+		if !ok {
+			continue
+		}
+		var user model.UserInfo
+		if res := dao.GormDB.First(&user, "uuid = ?", contactApply.UserId); res.Error != nil {
+			zlog.Error(res.Error.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		rsp = append(rsp, respond.GroupApplyListRespond{
+			GroupId:       contactApply.ContactId,
+			GroupName:     groupName,
+			ContactId:     user.Uuid,
+			ContactName:   user.Nickname,
+			ContactAvatar: user.Avatar,
+			Message:       contactApply.Message,
+			LastApplyAt:   contactApply.LastApplyAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return "获取成功", rsp, 0
+}
+
 // BlackApply 拉黑申请
 func (ucs *UserContactService) BlackApply(req *request.BlackApplyRequest) (string, int) {
+	// 加群申请只有群主和管理员可以处理
+	if req.OwnerId[0] == 'G' {
+		if _, message, ret := validate.CheckGroupOperator(req.OwnerId, req.OperatorId, validate.GroupPermApproveJoin); ret != 0 {
+			return message, ret
+		}
+	}
 	var contactApply model.ContactApply
 	// 判断是否已经拉黑
+	// 申请必须是发给调用方（或调用方管理的群聊）的，否则视为不存在
 	if res := dao.GormDB.Where("contact_id = ? AND user_id = ?", req.OwnerId, req.ContactId).First(&contactApply); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "申请不存在", -2
+		}
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	if req.OwnerId[0] == 'G' {
+		if message, ret := handleGroupApply(&contactApply, enum.BLACK_, req.OperatorId); ret != 0 {
+			return message, ret
+		}
+		return "已拉黑该申请", 0
+	}
 	// 拉黑
 	contactApply.Status = enum.BLACK_
 	if res := dao.GormDB.Save(&contactApply); res.Error != nil {
@@ -3,6 +3,8 @@ package service
 import (
 	"Kama-Chat/initialize/dao"
 	"Kama-Chat/initialize/zlog"
+	myjwt "Kama-Chat/lib/jwt"
+	"Kama-Chat/lib/password"
 	myredis "Kama-Chat/lib/redis"
 	"Kama-Chat/lib/sms"
 	"Kama-Chat/model"
@@ -52,11 +54,20 @@ func (uis *UserInfoService) Register(req *request.RegisterRequest) (string, *res
 	if ret != 0 {
 		return message, nil, ret
 	}
+	if message, ret := validate.CheckPasswordValid(req.Password); ret != 0 {
+		return message, nil, ret
+	}
+	// 密码加盐哈希后再入库
+	hashedPassword, err := password.Hash(req.Password)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
 	// 创建用户
 	var newUser model.UserInfo
 	newUser.Uuid = "U" + random.GetNowAndLenRandomString(11)
 	newUser.Telephone = req.Telephone
-	newUser.Password = req.Password
+	newUser.Password = hashedPassword
 	newUser.Nickname = req.Nickname
 	newUser.Avatar = "https://cube.elemecdn.com/0/88/03b0d39583f48206768a7534e55bcpng.png"
 	newUser.CreatedAt = time.Now()
@@ -95,7 +106,6 @@ func (uis *UserInfoService) Register(req *request.RegisterRequest) (string, *res
 
 // Login 登录
 func (uis *UserInfoService) Login(req *request.LoginRequest) (string, *respond.LoginRespond, int) {
-	password := req.Password
 	var user model.UserInfo
 	// 获取用户信息
 	res := dao.GormDB.First(&user, "telephone = ?", req.Telephone)
@@ -108,12 +118,21 @@ func (uis *UserInfoService) Login(req *request.LoginRequest) (string, *respond.L
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, nil, -1
 	}
-	// 密码校验 TODO 密码目前未加密
-	if user.Password != password {
+	// 密码校验
+	ok, needRehash := password.Verify(user.Password, req.Password)
+	if !ok {
 		message := "密码不正确，请重试"
 		zlog.Error(message)
 		return message, nil, -2
 	}
+	// 历史明文密码在首次登录成功时迁移为哈希，迁移失败不影响本次登录
+	if needRehash {
+		if hashedPassword, err := password.Hash(req.Password); err != nil {
+			zlog.Error(err.Error())
+		} else if res := dao.GormDB.Model(&user).Update("password", hashedPassword); res.Error != nil {
+			zlog.Error(res.Error.Error())
+		}
+	}
 	// 登录成功，返回用户信息
 	loginRsp := &respond.LoginRespond{
 		Uuid:      user.Uuid,
@@ -129,10 +148,48 @@ func (uis *UserInfoService) Login(req *request.LoginRequest) (string, *respond.L
 	}
 	year, month, day := user.CreatedAt.Date()
 	loginRsp.CreatedAt = fmt.Sprintf("%d.%d.%d", year, month, day)
+	// 签发访问token
+	token, expiresAt, err := myjwt.GenerateToken(user.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	loginRsp.Token = token
+	loginRsp.ExpiresAt = expiresAt.Unix()
 
 	return "登陆成功", loginRsp, 0
 }
 
+// UpdatePassword 修改密码，需要校验旧密码
+func (uis *UserInfoService) UpdatePassword(req *request.UpdatePasswordRequest) (string, int) {
+	var user model.UserInfo
+	if res := dao.GormDB.First(&user, "uuid = ?", req.Uuid); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "用户不存在", -2
+		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if ok, _ := password.Verify(user.Password, req.OldPassword); !ok {
+		message := "旧密码不正确，请重试"
+		zlog.Info(message)
+		return message, -2
+	}
+	if message, ret := validate.CheckPasswordValid(req.NewPassword); ret != 0 {
+		return message, ret
+	}
+	hashedPassword, err := password.Hash(req.NewPassword)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if res := dao.GormDB.Model(&user).Update("password", hashedPassword); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	return "修改密码成功", 0
+}
+
 // UpdateUserInfo 修改用户信息
 // 某用户修改了信息，可能会影响contact_user_list，不需要删除redis的contact_user_list，timeout之后会自己更新
 // 但是需要更新redis的user_info，因为可能影响用户搜索
@@ -259,6 +316,10 @@ func (uis *UserInfoService) AbleUsers(req *request.AbleUsersRequest) (string, in
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	// 只能管理角色低于自己的用户
+	if message, ret := validate.CheckCanManageUsers(req.OwnerId, users); ret != 0 {
+		return message, ret
+	}
 	// 遍历更新状态
 	for _, user := range users {
 		user.Status = enum.NORMAL
@@ -284,6 +345,10 @@ func (uis *UserInfoService) DisableUsers(req *request.AbleUsersRequest) (string,
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	// 只能管理角色低于自己的用户
+	if message, ret := validate.CheckCanManageUsers(req.OwnerId, users); ret != 0 {
+		return message, ret
+	}
 	// 遍历更新状态
 	for _, user := range users {
 		user.Status = enum.DISABLE
@@ -327,6 +392,10 @@ func (uis *UserInfoService) DeleteUsers(req *request.AbleUsersRequest) (string,
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	// 只能管理角色低于自己的用户
+	if message, ret := validate.CheckCanManageUsers(req.OwnerId, users); ret != 0 {
+		return message, ret
+	}
 	// 遍历更新状态
 	for _, user := range users {
 		user.DeletedAt.Valid = true
@@ -414,12 +483,20 @@ func (uis *UserInfoService) DeleteUsers(req *request.AbleUsersRequest) (string,
 
 // SetAdmin 设置管理员
 func (uis *UserInfoService) SetAdmin(req *request.AbleUsersRequest) (string, int) {
+	// 只能在普通用户和管理员之间切换，超级管理员通过配置指定
+	if req.IsAdmin != enum.ROLE_USER && req.IsAdmin != enum.ROLE_ADMIN {
+		return "只能设置为普通用户或管理员", -2
+	}
 	var users []model.UserInfo
 	// 获取用户信息
-	if res := dao.GormDB.Where("uuid = (?)", req.UuidList).Find(&users); res.Error != nil {
+	if res := dao.GormDB.Where("uuid in (?)", req.UuidList).Find(&users); res.Error != nil {
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	// 只能管理角色低于自己的用户
+	if message, ret := validate.CheckCanManageUsers(req.OwnerId, users); ret != 0 {
+		return message, ret
+	}
 	// 遍历更新状态
 	for _, user := range users {
 		user.IsAdmin = req.IsAdmin
@@ -427,6 +504,10 @@ func (uis *UserInfoService) SetAdmin(req *request.AbleUsersRequest) (string, int
 			zlog.Error(res.Error.Error())
 			return constants.SYSTEM_ERROR, -1
 		}
+		// 角色变化后用户信息缓存失效
+		if err := myredis.DelKeysWithPattern("user_info_" + user.Uuid); err != nil {
+			zlog.Error(err.Error())
+		}
 	}
 	return "设置管理员成功", 0
 }
@@ -480,6 +561,14 @@ func (uis *UserInfoService) SmsLogin(req *request.SmsLoginRequest) (string, *res
 	}
 	year, month, day := user.CreatedAt.Date()
 	loginRsp.CreatedAt = fmt.Sprintf("%d.%d.%d", year, month, day)
+	// 签发访问token
+	token, expiresAt, err := myjwt.GenerateToken(user.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	loginRsp.Token = token
+	loginRsp.ExpiresAt = expiresAt.Unix()
 
 	return "登陆成功", loginRsp, 0
 }
@@ -0,0 +1,36 @@
+package chat
+
+import (
+	"Kama-Chat/lib/chat"
+	"Kama-Chat/model/request"
+	"Kama-Chat/utils/enum"
+	"testing"
+)
+
+func TestValidateChatMessage(t *testing.T) {
+	valid := []request.ChatMessageRequest{
+		{ReceiveId: "U1", Type: enum.Text, Content: "hi"},
+		{ReceiveId: "G1", Type: enum.File, Url: "/static/files/a.pdf"},
+		{ReceiveId: "G1", Type: enum.Voice, Url: "/static/voices/a.m4a", Duration: 3, Waveform: []int{10, 20}},
+		{ReceiveId: "U1", Type: enum.AudioOrVideo},
+	}
+	for _, req := range valid {
+		if code, message := chat.ValidateChatMessage(req); code != "" {
+			t.Fatalf("合法的消息不应被拒绝: %+v %s", req, message)
+		}
+	}
+	invalid := []request.ChatMessageRequest{
+		{ReceiveId: "", Type: enum.Text, Content: "hi"},
+		{ReceiveId: "X1", Type: enum.Text, Content: "hi"},
+		{ReceiveId: "U1", Type: enum.Text},
+		{ReceiveId: "U1", Type: enum.File},
+		{ReceiveId: "U1", Type: enum.Voice, Url: "/static/voices/a.m4a"},
+		{ReceiveId: "G1", Type: enum.AudioOrVideo},
+		{ReceiveId: "U1", Type: 9, Content: "hi"},
+	}
+	for _, req := range invalid {
+		if code, _ := chat.ValidateChatMessage(req); code != enum.ErrInvalidMessage {
+			t.Fatalf("不合法的消息应返回%s: %+v", enum.ErrInvalidMessage, req)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"Kama-Chat/lib/chat"
+	"testing"
+)
+
+func newClient(uuid string, deviceId string) *chat.Client {
+	return &chat.Client{
+		Uuid:     uuid,
+		DeviceId: deviceId,
+		SendBack: make(chan *chat.MessageBack, 1),
+	}
+}
+
+func TestClientSetMultiDevice(t *testing.T) {
+	clients := make(chat.ClientSet)
+	phone := newClient("U1", "phone")
+	desktop := newClient("U1", "desktop")
+	if old := clients.Add(phone); old != nil {
+		t.Fatal("首次添加不应顶替连接")
+	}
+	if old := clients.Add(desktop); old != nil {
+		t.Fatal("不同设备不应互相顶替")
+	}
+	if n := len(clients.Devices("U1")); n != 2 {
+		t.Fatalf("期望2个设备，实际%d", n)
+	}
+	if sent := clients.SendToUser("U1", &chat.MessageBack{Uuid: "M1"}); sent != 2 {
+		t.Fatalf("消息应投递到2个设备，实际%d", sent)
+	}
+	// 只移除手机，桌面端不受影响
+	if !clients.Remove(phone) {
+		t.Fatal("移除手机失败")
+	}
+	if clients.Get("U1", "desktop") != desktop || !clients.Online("U1") {
+		t.Fatal("桌面端应仍然在线")
+	}
+	clients.Remove(desktop)
+	if clients.Online("U1") {
+		t.Fatal("所有设备移除后应离线")
+	}
+}
+
+func TestClientSetReplaceSameDevice(t *testing.T) {
+	clients := make(chat.ClientSet)
+	first := newClient("U1", "phone")
+	second := newClient("U1", "phone")
+	clients.Add(first)
+	if old := clients.Add(second); old != first {
+		t.Fatal("同一设备重连应顶替旧连接")
+	}
+	// 旧连接随后的登出不能把新连接删掉
+	if clients.Remove(first) {
+		t.Fatal("旧连接不应被移除")
+	}
+	if clients.Get("U1", "phone") != second {
+		t.Fatal("新连接应保留")
+	}
+}
+
+func TestClientSetSendToDevice(t *testing.T) {
+	clients := make(chat.ClientSet)
+	phone := newClient("U1", "phone")
+	desktop := newClient("U1", "desktop")
+	clients.Add(phone)
+	clients.Add(desktop)
+	if !clients.SendToDevice(phone, &chat.MessageBack{Message: []byte("presence")}) {
+		t.Fatal("事件应投递到手机")
+	}
+	if len(desktop.SendBack) != 0 {
+		t.Fatal("事件只应投递到发起查询的设备")
+	}
+	// 被顶替的旧连接不再接收事件
+	clients.Add(newClient("U1", "phone"))
+	if clients.SendToDevice(phone, &chat.MessageBack{}) {
+		t.Fatal("已被顶替的连接不应收到事件")
+	}
+}
@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"Kama-Chat/global"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/lib/chat"
+	myredis "Kama-Chat/lib/redis"
+	"github.com/alicebob/miniredis/v2"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 同一发送者的客户端消息id在去重时间内只能占用一次，过期后可以再次使用
+func TestClaimClientMsg(t *testing.T) {
+	global.CONFIG.LogConfig.LogPath = filepath.Join(t.TempDir(), "test.log")
+	zlog.InitLogger()
+	server := miniredis.RunT(t)
+	host, port, _ := net.SplitHostPort(server.Addr())
+	global.CONFIG.RedisConfig.Host = host
+	global.CONFIG.RedisConfig.Port, _ = strconv.Atoi(port)
+	global.CONFIG.ChatConfig.DedupWindow = 60
+	myredis.InitRedis()
+
+	if !chat.ClaimClientMsg("U1", "C1") {
+		t.Fatal("第一次占用应成功")
+	}
+	if chat.ClaimClientMsg("U1", "C1") {
+		t.Fatal("去重时间内重复占用应失败")
+	}
+	if !chat.ClaimClientMsg("U2", "C1") {
+		t.Fatal("不同发送者可以使用相同的客户端消息id")
+	}
+	for i := 0; i < 2; i++ {
+		if !chat.ClaimClientMsg("U1", "") {
+			t.Fatal("没有客户端消息id的消息不受限制")
+		}
+	}
+	server.FastForward(61 * time.Second)
+	if !chat.ClaimClientMsg("U1", "C1") {
+		t.Fatal("超过去重时间后客户端消息id可以再次使用")
+	}
+}
@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"Kama-Chat/lib/chat"
+	"Kama-Chat/utils/constants"
+	"fmt"
+	"testing"
+)
+
+// uuidsOf 生成n条按先后排列的消息uuid：M0、M1……
+func uuidsOf(n int) []string {
+	uuids := make([]string, n)
+	for i := range uuids {
+		uuids[i] = fmt.Sprintf("M%d", i)
+	}
+	return uuids
+}
+
+func TestPageLimit(t *testing.T) {
+	cases := []struct{ limit, want int }{
+		{0, constants.MESSAGE_PAGE_SIZE},
+		{-1, constants.MESSAGE_PAGE_SIZE},
+		{10, 10},
+		{constants.MESSAGE_PAGE_MAX + 1, constants.MESSAGE_PAGE_MAX},
+	}
+	for _, c := range cases {
+		if got := chat.PageLimit(c.limit); got != c.want {
+			t.Fatalf("PageLimit(%d)应为%d，实际%d", c.limit, c.want, got)
+		}
+	}
+}
+
+func TestCachedPage(t *testing.T) {
+	// 缓存不满时缓存的就是整个会话
+	partial := uuidsOf(10)
+	// 缓存已满时更早的消息只在数据库中
+	full := uuidsOf(constants.MESSAGE_CACHE_SIZE)
+	cases := []struct {
+		name       string
+		uuids      []string
+		cursor     chat.MessageCursor
+		limit      int
+		start, end int
+		ok         bool
+	}{
+		{"最近一页", partial, chat.MessageCursor{}, 4, 6, 10, true},
+		{"整个会话不够一页", partial, chat.MessageCursor{}, 20, 0, 10, true},
+		{"缓存已满时最近一页", full, chat.MessageCursor{}, 4, constants.MESSAGE_CACHE_SIZE - 4, constants.MESSAGE_CACHE_SIZE, true},
+		{"向上翻页", partial, chat.MessageCursor{Before: "M6"}, 4, 2, 6, true},
+		{"向上翻到会话开头", partial, chat.MessageCursor{Before: "M2"}, 4, 0, 2, true},
+		{"缓存已满时向上翻出缓存", full, chat.MessageCursor{Before: "M2"}, 4, 0, 0, false},
+		{"向下翻页", partial, chat.MessageCursor{After: "M3"}, 4, 4, 8, true},
+		{"向下翻到最新", partial, chat.MessageCursor{After: "M8"}, 4, 9, 10, true},
+		{"定位到消息附近", partial, chat.MessageCursor{Around: "M5"}, 4, 3, 7, true},
+		{"缓存已满时定位到开头附近", full, chat.MessageCursor{Around: "M1"}, 4, 0, 0, false},
+		{"游标不在缓存中", partial, chat.MessageCursor{Before: "X"}, 4, 0, 0, false},
+		{"按序号同步总是查数据库", partial, chat.MessageCursor{AfterSeq: 3}, 4, 0, 0, false},
+	}
+	for _, c := range cases {
+		start, end, ok := chat.CachedPage(c.uuids, c.cursor, c.limit)
+		if ok != c.ok || (ok && (start != c.start || end != c.end)) {
+			t.Fatalf("%s: 期望[%d,%d) %v，实际[%d,%d) %v", c.name, c.start, c.end, c.ok, start, end, ok)
+		}
+	}
+}
+
+func TestMessageCursorTarget(t *testing.T) {
+	if target := (chat.MessageCursor{Before: "B", After: "A", Around: "C"}).Target(); target != "A" {
+		t.Fatalf("同时传多个游标时After优先，实际%s", target)
+	}
+	if target := (chat.MessageCursor{Before: "B", Around: "C"}).Target(); target != "B" {
+		t.Fatalf("没有After时Before优先，实际%s", target)
+	}
+}
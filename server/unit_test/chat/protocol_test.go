@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"Kama-Chat/lib/chat"
+	"Kama-Chat/model/respond"
+	"Kama-Chat/utils/enum"
+	"encoding/json"
+	"testing"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	if version, protocol, ok := chat.NegotiateVersion(nil); !ok || version != chat.ProtocolV1 || protocol != "" {
+		t.Fatalf("不带子协议时应使用v1: %d %s %v", version, protocol, ok)
+	}
+	if version, protocol, ok := chat.NegotiateVersion([]string{"other", "kama.v2", "kama.v1"}); !ok || version != chat.ProtocolV2 || protocol != "kama.v2" {
+		t.Fatalf("应选择客户端给出的第一个支持的版本: %d %s %v", version, protocol, ok)
+	}
+	if _, _, ok := chat.NegotiateVersion([]string{"kama.v9"}); ok {
+		t.Fatal("不支持的子协议应拒绝连接")
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	frame, ok := chat.DecodeFrame(chat.ProtocolV1, []byte(`{"event":"ack","message_ids":["M1"]}`))
+	if !ok || frame.Type != enum.EventAck {
+		t.Fatalf("v1带event的帧应为事件帧: %+v", frame)
+	}
+	frame, ok = chat.DecodeFrame(chat.ProtocolV1, []byte(`{"receive_id":"U1","content":"hi"}`))
+	if !ok || frame.Type != enum.FrameMessage {
+		t.Fatalf("v1不带event的帧应为聊天消息: %+v", frame)
+	}
+	frame, ok = chat.DecodeFrame(chat.ProtocolV2, []byte(`{"type":"read","id":"c1","payload":{"receive_id":"U1"}}`))
+	if !ok || frame.Type != enum.EventRead || frame.Id != "c1" || string(frame.Payload) != `{"receive_id":"U1"}` {
+		t.Fatalf("v2信封解析错误: %+v", frame)
+	}
+	for _, data := range []string{`not json`, `{"id":"c1"}`, `{"event":"ack"}`} {
+		if _, ok := chat.DecodeFrame(chat.ProtocolV2, []byte(data)); ok {
+			t.Fatalf("不合法的v2信封应被拒绝: %s", data)
+		}
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	message := &chat.MessageBack{Message: []byte(`{"uuid":"M1","content":"hi"}`), Uuid: "M1"}
+	if string(chat.EncodeFrame(chat.ProtocolV1, message)) != string(message.Message) {
+		t.Fatal("v1应原样发送")
+	}
+
+	var frame respond.FrameRespond
+	if err := json.Unmarshal(chat.EncodeFrame(chat.ProtocolV2, message), &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Version != chat.ProtocolV2 || frame.Type != enum.FrameMessage || frame.Id != "M1" || frame.Error != nil {
+		t.Fatalf("聊天消息的信封错误: %+v", frame)
+	}
+
+	errorEvent := &chat.MessageBack{Message: []byte(`{"event":"error","code":"not_friend","message":"对方不是你的好友","client_msg_id":"c1"}`)}
+	frame = respond.FrameRespond{}
+	if err := json.Unmarshal(chat.EncodeFrame(chat.ProtocolV2, errorEvent), &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != enum.EventError || frame.Id != "c1" || frame.Payload != nil || frame.Error == nil || frame.Error.Code != enum.ErrNotFriend {
+		t.Fatalf("错误帧的信封错误: %+v", frame)
+	}
+
+	sendAck := &chat.MessageBack{Message: []byte(`{"event":"send_ack","client_msg_id":"c2","message_id":"M2"}`)}
+	frame = respond.FrameRespond{}
+	if err := json.Unmarshal(chat.EncodeFrame(chat.ProtocolV2, sendAck), &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != enum.EventSendAck || frame.Id != "c2" || frame.Error != nil {
+		t.Fatalf("发送回执的信封错误: %+v", frame)
+	}
+}
@@ -0,0 +1,28 @@
+package chat
+
+import (
+	"Kama-Chat/lib/chat"
+	"Kama-Chat/model"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuoteOf(t *testing.T) {
+	long := strings.Repeat("长", 60)
+	quote := chat.QuoteOf(model.Message{Uuid: "M1", Type: enum.Text, Content: long})
+	if quote.Snippet != strings.Repeat("长", 50)+"..." {
+		t.Fatalf("长文本应按字符截断: %s", quote.Snippet)
+	}
+	quote = chat.QuoteOf(model.Message{Uuid: "M2", Type: enum.File, FileName: "a.pdf"})
+	if quote.Snippet != "[文件] a.pdf" {
+		t.Fatalf("文件摘要不对: %s", quote.Snippet)
+	}
+	quote = chat.QuoteOf(model.Message{Uuid: "M3", Type: enum.Text, Content: "hello", RecalledAt: sql.NullTime{Time: time.Now(), Valid: true}})
+	if !quote.IsRecalled || quote.Snippet != constants.RECALLED_TEXT {
+		t.Fatal("撤回的消息摘要应为撤回提示")
+	}
+}
@@ -0,0 +1,93 @@
+package chat
+
+import (
+	"Kama-Chat/lib/chat"
+	"Kama-Chat/model"
+	"Kama-Chat/unit_test/testdb"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTextMessage(uuid string, sendId string, receiveId string) model.Message {
+	return model.Message{
+		Uuid:      uuid,
+		SessionId: "S1",
+		SendId:    sendId,
+		SendName:  sendId,
+		ReceiveId: receiveId,
+		Content:   uuid,
+		CreatedAt: time.Now(),
+	}
+}
+
+// 同一会话的消息无论由哪一方发送、是否并发写入，序号都从1开始连续且不重复
+func TestCreateMessageAllocatesSeq(t *testing.T) {
+	db := testdb.Open(t, &model.Message{}, &model.ConversationSeq{})
+	first := newTextMessage("M0", "U1", "U2")
+	if err := chat.CreateMessage(&first); err != nil {
+		t.Fatal(err)
+	}
+	if first.ConversationId != "U1_U2" || first.Seq != 1 {
+		t.Fatalf("会话的第一条消息序号应为1: %s %d", first.ConversationId, first.Seq)
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			message := newTextMessage(fmt.Sprintf("M%d", i), "U2", "U1")
+			if i%2 == 0 {
+				message = newTextMessage(fmt.Sprintf("M%d", i), "U1", "U2")
+			}
+			errs <- chat.CreateMessage(&message)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var seqs []int64
+	if err := db.Model(&model.Message{}).Where("conversation_id = ?", "U1_U2").Order("seq ASC").Pluck("seq", &seqs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(seqs) != n+1 {
+		t.Fatalf("应有%d条消息，实际%d", n+1, len(seqs))
+	}
+	for i, seq := range seqs {
+		if seq != int64(i+1) {
+			t.Fatalf("序号应连续，第%d条为%d", i+1, seq)
+		}
+	}
+	var current model.ConversationSeq
+	if err := db.First(&current, "conversation_id = ?", "U1_U2").Error; err != nil {
+		t.Fatal(err)
+	}
+	if current.Seq != n+1 {
+		t.Fatalf("已分配的最大序号应为%d，实际%d", n+1, current.Seq)
+	}
+
+	// 其他会话的序号单独计数
+	group := newTextMessage("G0", "U1", "G1")
+	if err := chat.CreateMessage(&group); err != nil {
+		t.Fatal(err)
+	}
+	if group.ConversationId != "G1" || group.Seq != 1 {
+		t.Fatalf("群聊的第一条消息序号应为1: %s %d", group.ConversationId, group.Seq)
+	}
+
+	// 会话内的序号有唯一索引，绕过分配直接写入重复序号会失败
+	duplicate := newTextMessage("MD", "U1", "U2")
+	duplicate.ConversationId = "U1_U2"
+	duplicate.Seq = 1
+	if err := db.Create(&duplicate).Error; err == nil {
+		t.Fatal("重复的会话序号应被唯一索引拒绝")
+	}
+}
@@ -0,0 +1,18 @@
+package chat
+
+import (
+	"Kama-Chat/lib/chat"
+	"testing"
+)
+
+func TestConversationId(t *testing.T) {
+	if chat.ConversationId("U1", "U2") != chat.ConversationId("U2", "U1") {
+		t.Fatal("单聊双方的会话id应相同")
+	}
+	if id := chat.ConversationId("U2", "U1"); id != "U1_U2" {
+		t.Fatalf("单聊会话id应按字典序拼接: %s", id)
+	}
+	if id := chat.ConversationId("U1", "G1"); id != "G1" {
+		t.Fatalf("群聊会话id应为群uuid: %s", id)
+	}
+}
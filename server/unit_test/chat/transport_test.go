@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"Kama-Chat/lib/chat"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeTransport 记录投递的消息，测试中代替通道和Kafka
+type fakeTransport struct {
+	published [][]byte
+}
+
+func (f *fakeTransport) Publish(data []byte) error {
+	f.published = append(f.published, data)
+	return nil
+}
+
+func (f *fakeTransport) Consume(handle func(data []byte)) {}
+
+func (f *fakeTransport) Close() error { return nil }
+
+func TestServerPublishUsesTransport(t *testing.T) {
+	fake := &fakeTransport{}
+	server := chat.NewServer(fake, nil)
+	if err := server.Publish([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.published) != 1 || string(fake.published[0]) != "hello" {
+		t.Fatalf("消息应交给传输层: %v", fake.published)
+	}
+}
+
+func TestChannelTransport(t *testing.T) {
+	transport := chat.NewChannelTransport(2)
+	for _, data := range []string{"a", "b"} {
+		if err := transport.Publish([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := transport.Publish([]byte("c")); !errors.Is(err, chat.ErrTransportFull) {
+		t.Fatalf("缓冲已满时应返回ErrTransportFull: %v", err)
+	}
+
+	received := make(chan string, 2)
+	done := make(chan struct{})
+	go func() {
+		transport.Consume(func(data []byte) { received <- string(data) })
+		close(done)
+	}()
+	for _, want := range []string{"a", "b"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("应按投递顺序处理，期望%s，实际%s", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("消息没有被处理")
+		}
+	}
+
+	if err := transport.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("关闭后Consume应返回")
+	}
+	if err := transport.Publish([]byte("d")); !errors.Is(err, chat.ErrTransportClosed) {
+		t.Fatalf("关闭后投递应返回ErrTransportClosed: %v", err)
+	}
+}
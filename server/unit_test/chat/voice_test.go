@@ -0,0 +1,40 @@
+package chat
+
+import (
+	"Kama-Chat/lib/chat"
+	"Kama-Chat/model"
+	"Kama-Chat/utils/constants"
+	"reflect"
+	"testing"
+)
+
+func TestValidateVoice(t *testing.T) {
+	if err := chat.ValidateVoice(5, []int{0, 50, 100}); err != nil {
+		t.Fatalf("合法的语音不应报错: %v", err)
+	}
+	cases := []struct {
+		duration int
+		waveform []int
+	}{
+		{0, []int{10}},
+		{constants.VOICE_MAX_DURATION + 1, []int{10}},
+		{5, nil},
+		{5, make([]int, constants.VOICE_WAVEFORM_SIZE+1)},
+		{5, []int{10, 101}},
+		{5, []int{-1}},
+	}
+	for _, c := range cases {
+		if err := chat.ValidateVoice(c.duration, c.waveform); err == nil {
+			t.Fatalf("时长%d、波形%v应校验失败", c.duration, c.waveform)
+		}
+	}
+}
+
+func TestWaveformOf(t *testing.T) {
+	if chat.WaveformOf(model.Message{}) != nil {
+		t.Fatal("非语音消息的波形应为nil")
+	}
+	if waveform := chat.WaveformOf(model.Message{Waveform: "[1,2,3]"}); !reflect.DeepEqual(waveform, []int{1, 2, 3}) {
+		t.Fatalf("波形解析不对: %v", waveform)
+	}
+}
@@ -0,0 +1,210 @@
+package cluster
+
+import (
+	"Kama-Chat/lib/chat"
+	"Kama-Chat/lib/cluster"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"testing"
+	"time"
+)
+
+// newNode 创建不投递消息的节点，用于只关心在线登记的测试
+func newNode(t *testing.T, id string, presence cluster.Presence, bus cluster.Bus) *cluster.Node {
+	node, err := cluster.NewNode(id, presence, bus, func(envelope *cluster.Envelope) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(node.Close)
+	return node
+}
+
+// newServer 创建加入集群的聊天服务器，其他节点转发过来的消息交给服务器投递
+func newServer(t *testing.T, id string, presence cluster.Presence, bus cluster.Bus) *chat.Server {
+	var server *chat.Server
+	node, err := cluster.NewNode(id, presence, bus, func(envelope *cluster.Envelope) {
+		server.DeliverFromCluster(envelope)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(node.Close)
+	server = chat.NewServer(chat.NewChannelTransport(1), node)
+	return server
+}
+
+// connect 在服务器上建立一个设备连接
+func connect(server *chat.Server, uuid string, deviceId string) *chat.Client {
+	client := &chat.Client{Uuid: uuid, DeviceId: deviceId, SendBack: make(chan *chat.MessageBack, 10)}
+	server.AddClient(client)
+	return client
+}
+
+// send 由服务器投递给用户的所有设备，包括其他节点上的设备
+func send(server *chat.Server, uuid string, messageUuid string, message string) {
+	server.SendToUser(uuid, &chat.MessageBack{Message: []byte(message), Uuid: messageUuid})
+}
+
+// nextMessage 读取下一条聊天消息，跳过欢迎等没有消息uuid的事件，超时或连接已关闭时返回nil
+func nextMessage(client *chat.Client, timeout time.Duration) *chat.MessageBack {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case messageBack, ok := <-client.SendBack:
+			// 连接已关闭
+			if !ok {
+				return nil
+			}
+			if messageBack.Uuid != "" {
+				return messageBack
+			}
+		case <-deadline:
+			return nil
+		}
+	}
+}
+
+func expectMessage(t *testing.T, client *chat.Client, messageUuid string) {
+	messageBack := nextMessage(client, 2*time.Second)
+	if messageBack == nil {
+		t.Fatalf("设备%s没有收到消息%s", client.DeviceId, messageUuid)
+	}
+	if messageBack.Uuid != messageUuid {
+		t.Fatalf("设备%s收到的消息不对: %s", client.DeviceId, messageBack.Uuid)
+	}
+}
+
+func expectNoMessage(t *testing.T, client *chat.Client) {
+	if messageBack := nextMessage(client, 50*time.Millisecond); messageBack != nil {
+		t.Fatalf("设备%s不应收到消息%s", client.DeviceId, messageBack.Uuid)
+	}
+}
+
+// testCrossNodeDelivery 用户的两个设备分别连在A、B两个服务器，消息从C服务器发出，两个设备都应收到且只收到一次
+func testCrossNodeDelivery(t *testing.T, presence cluster.Presence, bus cluster.Bus) {
+	serverA := newServer(t, "A", presence, bus)
+	serverB := newServer(t, "B", presence, bus)
+	serverC := newServer(t, "C", presence, bus)
+	phone := connect(serverA, "U1", "phone")
+	desktop := connect(serverB, "U1", "desktop")
+	sender := connect(serverC, "U2", "phone")
+
+	send(serverC, "U1", "M1", "hello")
+	expectMessage(t, phone, "M1")
+	expectMessage(t, desktop, "M1")
+	expectNoMessage(t, sender)
+	expectNoMessage(t, phone)
+
+	// 手机端下线后只投递到桌面端
+	serverA.RemoveClient("U1")
+	send(serverC, "U1", "M2", "world")
+	expectMessage(t, desktop, "M2")
+	expectNoMessage(t, phone)
+}
+
+// countingBus 记录每个节点收到的发布次数
+type countingBus struct {
+	*cluster.MemoryBus
+	published map[string]int
+}
+
+func (b *countingBus) Publish(nodeId string, envelope *cluster.Envelope) error {
+	b.published[nodeId]++
+	return b.MemoryBus.Publish(nodeId, envelope)
+}
+
+// 群聊消息一次转发给所有成员，每个节点只发布一次，只带上在该节点上有连接的成员
+func TestForwardPublishesOncePerNode(t *testing.T) {
+	presence := cluster.NewMemoryPresence()
+	bus := &countingBus{MemoryBus: cluster.NewMemoryBus(), published: make(map[string]int)}
+	serverA := newServer(t, "A", presence, bus)
+	serverB := newServer(t, "B", presence, bus)
+	serverC := newServer(t, "C", presence, bus)
+	u1 := connect(serverA, "U1", "phone")
+	u2 := connect(serverA, "U2", "phone")
+	u3 := connect(serverB, "U3", "phone")
+	sender := connect(serverC, "U4", "phone")
+
+	serverC.SendToUsers(&chat.MessageBack{Message: []byte("hello"), Uuid: "M1"}, "U1", "U2", "U3", "U4", "U5")
+	expectMessage(t, u1, "M1")
+	expectMessage(t, u2, "M1")
+	expectMessage(t, u3, "M1")
+	expectMessage(t, sender, "M1")
+	if bus.published["A"] != 1 || bus.published["B"] != 1 || bus.published["C"] != 0 {
+		t.Fatalf("每个节点应只发布一次，实际%v", bus.published)
+	}
+}
+
+func TestCrossNodeDeliveryMemory(t *testing.T) {
+	testCrossNodeDelivery(t, cluster.NewMemoryPresence(), cluster.NewMemoryBus())
+}
+
+func TestCrossNodeDeliveryRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	testCrossNodeDelivery(t, cluster.NewRedisPresence(client), cluster.NewRedisBus(client))
+}
+
+func TestOfflineKeepsNewerRegistration(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	presence := cluster.NewRedisPresence(client)
+	// 设备先连A后重连到B，A随后的注销不能删掉B的登记
+	if err := presence.Online("U1", "phone", "A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := presence.Online("U1", "phone", "B"); err != nil {
+		t.Fatal(err)
+	}
+	if err := presence.Offline("U1", "phone", "A"); err != nil {
+		t.Fatal(err)
+	}
+	userNodes, err := presence.Nodes("U1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes := userNodes["U1"]; len(nodes) != 1 || nodes[0] != "B" {
+		t.Fatalf("登记应保留在B节点，实际%v", nodes)
+	}
+}
+
+func TestForwardCleansUnreachableNode(t *testing.T) {
+	presence := cluster.NewMemoryPresence()
+	bus := cluster.NewMemoryBus()
+	nodeA := newNode(t, "A", presence, bus)
+	// 节点dead已经下线但登记还在
+	if err := presence.Online("U1", "phone", "dead"); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodeA.Forward(&cluster.Envelope{UserIds: []string{"U1"}, MessageUuid: "M1", Message: []byte("hello")}); err != nil {
+		t.Fatal(err)
+	}
+	userNodes, _ := presence.Nodes("U1")
+	if nodes := userNodes["U1"]; len(nodes) != 0 {
+		t.Fatalf("不可达节点的登记应被清理，实际%v", nodes)
+	}
+}
+
+func TestOnlineElsewhere(t *testing.T) {
+	presence := cluster.NewMemoryPresence()
+	bus := cluster.NewMemoryBus()
+	nodeA := newNode(t, "A", presence, bus)
+	nodeB := newNode(t, "B", presence, bus)
+	if err := nodeA.Online("U1", "phone"); err != nil {
+		t.Fatal(err)
+	}
+	if online, err := nodeA.OnlineElsewhere("U1"); err != nil || online {
+		t.Fatal("只在本节点在线时不算在其他节点在线")
+	}
+	if online, err := nodeB.OnlineElsewhere("U1"); err != nil || !online {
+		t.Fatal("节点B应看到用户在节点A在线")
+	}
+	if err := nodeA.Offline("U1", "phone"); err != nil {
+		t.Fatal(err)
+	}
+	if online, _ := nodeB.OnlineElsewhere("U1"); online {
+		t.Fatal("下线后不应在线")
+	}
+}
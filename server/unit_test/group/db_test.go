@@ -0,0 +1,35 @@
+package group
+
+import (
+	"Kama-Chat/model"
+	"Kama-Chat/unit_test/testdb"
+	"Kama-Chat/utils/enum"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// setupDB 重建群聊相关的表
+func setupDB(t *testing.T) *gorm.DB {
+	return testdb.Open(t, &model.GroupInfo{}, &model.GroupMember{}, &model.UserContact{}, &model.Session{}, &model.ContactApply{})
+}
+
+// createGroup 创建一个只有群主的群聊
+func createGroup(t *testing.T, db *gorm.DB, groupId string, ownerId string) {
+	group := model.GroupInfo{Uuid: groupId, Name: groupId, OwnerId: ownerId, MemberCnt: 1}
+	if err := db.Create(&group).Error; err != nil {
+		t.Fatal(err)
+	}
+	owner := model.GroupMember{GroupId: groupId, UserId: ownerId, Role: enum.GROUP_OWNER, JoinedAt: group.CreatedAt}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// memberCnt 读取群人数
+func memberCnt(t *testing.T, db *gorm.DB, groupId string) int {
+	var group model.GroupInfo
+	if err := db.First(&group, "uuid = ?", groupId).Error; err != nil {
+		t.Fatal(err)
+	}
+	return group.MemberCnt
+}
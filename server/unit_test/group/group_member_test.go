@@ -0,0 +1,132 @@
+package group
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/service"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/validate"
+	"testing"
+	"time"
+)
+
+func TestEnterAndLeaveGroup(t *testing.T) {
+	db := setupDB(t)
+	createGroup(t, db, "G1", "U1")
+	groupInfoService := &service.GroupInfoService{}
+
+	if message, ret := groupInfoService.EnterGroupDirectly(&request.EnterGroupDirectlyRequest{OwnerId: "G1", ContactId: "U2"}); ret != 0 {
+		t.Fatalf("进群失败: %s", message)
+	}
+	member, _, ret := validate.GetGroupMember("G1", "U2")
+	if ret != 0 || member.Role != enum.GROUP_MEMBER {
+		t.Fatalf("进群后应是普通成员: ret=%d role=%d", ret, member.Role)
+	}
+	if cnt := memberCnt(t, db, "G1"); cnt != 2 {
+		t.Fatalf("进群后群人数应为2，实际%d", cnt)
+	}
+	// 重复进群不会重复增加群人数
+	if _, ret := groupInfoService.EnterGroupDirectly(&request.EnterGroupDirectlyRequest{OwnerId: "G1", ContactId: "U2"}); ret != -2 {
+		t.Fatalf("已经在群里时应返回-2，实际%d", ret)
+	}
+	if cnt := memberCnt(t, db, "G1"); cnt != 2 {
+		t.Fatalf("重复进群后群人数应为2，实际%d", cnt)
+	}
+
+	if message, ret := groupInfoService.LeaveGroup(&request.LeaveGroupRequest{UserId: "U2", GroupId: "G1"}); ret != 0 {
+		t.Fatalf("退群失败: %s", message)
+	}
+	if _, _, ret := validate.GetGroupMember("G1", "U2"); ret != -2 {
+		t.Fatalf("退群后不应再是群成员，实际%d", ret)
+	}
+	if cnt := memberCnt(t, db, "G1"); cnt != 1 {
+		t.Fatalf("退群后群人数应为1，实际%d", cnt)
+	}
+	// 不在群里时再次移除不会减少群人数
+	if _, ret := groupInfoService.LeaveGroup(&request.LeaveGroupRequest{UserId: "U2", GroupId: "G1"}); ret != 0 {
+		t.Fatalf("重复退群应成功，实际%d", ret)
+	}
+	if cnt := memberCnt(t, db, "G1"); cnt != 1 {
+		t.Fatalf("重复退群后群人数应为1，实际%d", cnt)
+	}
+}
+
+func TestEnterGroupRequiresDirectMode(t *testing.T) {
+	db := setupDB(t)
+	createGroup(t, db, "G1", "U1")
+	if err := db.Model(&model.GroupInfo{}).Where("uuid = ?", "G1").Update("add_mode", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, ret := (&service.GroupInfoService{}).EnterGroupDirectly(&request.EnterGroupDirectlyRequest{OwnerId: "G1", ContactId: "U2"}); ret != -2 {
+		t.Fatalf("需要审核的群聊不能直接进群，实际%d", ret)
+	}
+	if _, _, ret := validate.GetGroupMember("G1", "U2"); ret != -2 {
+		t.Fatal("被拒绝后不应成为群成员")
+	}
+}
+
+// 群成员以成员表为准，联系人记录的状态不再决定是否在群里
+func TestMembershipFollowsGroupMember(t *testing.T) {
+	db := setupDB(t)
+	createGroup(t, db, "G1", "U1")
+	createGroup(t, db, "G2", "U2")
+	contact := model.UserContact{UserId: "U1", ContactId: "G2", ContactType: enum.GROUP, Status: enum.NORMAL, CreatedAt: time.Now(), UpdateAt: time.Now()}
+	if err := db.Create(&contact).Error; err != nil {
+		t.Fatal(err)
+	}
+	groupIds, _, ret := validate.GetJoinedGroupIds("U1")
+	if ret != 0 || len(groupIds) != 1 || groupIds[0] != "G1" {
+		t.Fatalf("U1只在G1中，实际%v", groupIds)
+	}
+	if _, _, ret := validate.GetGroupMember("G2", "U1"); ret != -2 {
+		t.Fatalf("只有联系人记录时不算群成员，实际%d", ret)
+	}
+}
+
+func TestMigrateGroupMembers(t *testing.T) {
+	db := setupDB(t)
+	// 迁移前群成员保存在群聊的json字段中
+	if err := db.Exec("ALTER TABLE group_info ADD COLUMN members JSON").Error; err != nil {
+		t.Fatal(err)
+	}
+	createdAt := time.Date(2024, 1, 1, 8, 0, 0, 0, time.Local)
+	joinedAt := time.Date(2024, 2, 1, 8, 0, 0, 0, time.Local)
+	group := model.GroupInfo{Uuid: "G1", Name: "G1", OwnerId: "U1", MemberCnt: 0, CreatedAt: createdAt}
+	if err := db.Create(&group).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`UPDATE group_info SET members = '["U1", "U2", "U3"]' WHERE uuid = ?`, "G1").Error; err != nil {
+		t.Fatal(err)
+	}
+	contact := model.UserContact{UserId: "U2", ContactId: "G1", ContactType: enum.GROUP, Status: enum.NORMAL, CreatedAt: joinedAt, UpdateAt: joinedAt}
+	if err := db.Create(&contact).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 重复执行不会重复插入
+	for i := 0; i < 2; i++ {
+		if err := dao.MigrateGroupMembers(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var members []model.GroupMember
+	if err := db.Order("user_id ASC").Find(&members, "group_id = ?", "G1").Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 {
+		t.Fatalf("应迁移3名成员，实际%d", len(members))
+	}
+	if members[0].UserId != "U1" || members[0].Role != enum.GROUP_OWNER {
+		t.Fatalf("群主角色不对: %+v", members[0])
+	}
+	if members[1].Role != enum.GROUP_MEMBER || !members[1].JoinedAt.Equal(joinedAt) {
+		t.Fatalf("入群时间应取联系人记录: %+v", members[1])
+	}
+	if !members[2].JoinedAt.Equal(createdAt) {
+		t.Fatalf("没有联系人记录时入群时间应取建群时间: %+v", members[2])
+	}
+	if cnt := memberCnt(t, db, "G1"); cnt != 3 {
+		t.Fatalf("群人数应按成员表重新计算为3，实际%d", cnt)
+	}
+}
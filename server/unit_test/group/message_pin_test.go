@@ -0,0 +1,62 @@
+package group
+
+import (
+	"Kama-Chat/model"
+	"Kama-Chat/model/request"
+	"Kama-Chat/service"
+	"Kama-Chat/utils/enum"
+	"testing"
+	"time"
+)
+
+// 只有群主和管理员可以置顶，全体群成员可以查看置顶消息，撤回后不再置顶
+func TestPinMessage(t *testing.T) {
+	db := setupDB(t)
+	if err := db.Migrator().DropTable(&model.Message{}, &model.MessagePin{}, &model.MessageEdit{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Message{}, &model.MessagePin{}, &model.MessageEdit{}); err != nil {
+		t.Fatal(err)
+	}
+	createGroup(t, db, "G1", "U1")
+	for _, member := range []model.GroupMember{
+		{GroupId: "G1", UserId: "U2", Role: enum.GROUP_ADMIN, JoinedAt: time.Now()},
+		{GroupId: "G1", UserId: "U3", Role: enum.GROUP_MEMBER, JoinedAt: time.Now()},
+	} {
+		if err := db.Create(&member).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	message := model.Message{Uuid: "M1", Type: enum.Text, Content: "hello", SendId: "U3", ReceiveId: "G1", ConversationId: "G1", Seq: 1, CreatedAt: time.Now()}
+	if err := db.Create(&message).Error; err != nil {
+		t.Fatal(err)
+	}
+	messageService := &service.MessageService{}
+
+	if _, ret := messageService.PinMessage(&request.PinMessageRequest{OwnerId: "U3", MessageId: "M1"}); ret != -2 {
+		t.Fatalf("普通成员不能置顶，实际%d", ret)
+	}
+	if message, ret := messageService.PinMessage(&request.PinMessageRequest{OwnerId: "U2", MessageId: "M1"}); ret != 0 {
+		t.Fatalf("管理员置顶失败: %s", message)
+	}
+	if _, ret := messageService.PinMessage(&request.PinMessageRequest{OwnerId: "U1", MessageId: "M1"}); ret != -2 {
+		t.Fatalf("重复置顶应返回-2，实际%d", ret)
+	}
+	_, pinList, ret := messageService.GetPinnedMessageList(&request.GetPinnedMessageListRequest{OwnerId: "U3", GroupId: "G1"})
+	if ret != 0 || len(pinList) != 1 || pinList[0].MessageId != "M1" || pinList[0].PinnedBy != "U2" {
+		t.Fatalf("群成员应看到置顶消息: ret=%d %+v", ret, pinList)
+	}
+	if _, _, ret := messageService.GetPinnedMessageList(&request.GetPinnedMessageListRequest{OwnerId: "U4", GroupId: "G1"}); ret != -2 {
+		t.Fatalf("非群成员不能查看置顶消息，实际%d", ret)
+	}
+
+	if message, ret := messageService.RecallMessage(&request.RecallMessageRequest{OwnerId: "U3", MessageId: "M1"}); ret != 0 {
+		t.Fatalf("撤回失败: %s", message)
+	}
+	if _, pinList, _ := messageService.GetPinnedMessageList(&request.GetPinnedMessageListRequest{OwnerId: "U3", GroupId: "G1"}); len(pinList) != 0 {
+		t.Fatalf("撤回后不应再置顶: %+v", pinList)
+	}
+	if _, ret := messageService.UnpinMessage(&request.UnpinMessageRequest{OwnerId: "U1", MessageId: "M1"}); ret != -2 {
+		t.Fatalf("未置顶时取消置顶应返回-2，实际%d", ret)
+	}
+}
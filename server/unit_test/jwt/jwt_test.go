@@ -0,0 +1,54 @@
+package jwt
+
+import (
+	"Kama-Chat/global"
+	myjwt "Kama-Chat/lib/jwt"
+	"errors"
+	"testing"
+)
+
+func initJwtConfig() {
+	global.CONFIG.JwtConfig.SigningKey = "unit-test-key"
+	global.CONFIG.JwtConfig.Issuer = "kama_chat"
+	global.CONFIG.JwtConfig.ExpiresTime = 1
+	global.CONFIG.JwtConfig.BufferTime = 0
+}
+
+func TestGenerateAndParseToken(t *testing.T) {
+	initJwtConfig()
+	token, _, err := myjwt.GenerateToken("U2024010112345678901")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := myjwt.ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Uuid != "U2024010112345678901" {
+		t.Fatalf("uuid不一致: %s", claims.Uuid)
+	}
+}
+
+func TestParseTokenWithWrongKey(t *testing.T) {
+	initJwtConfig()
+	token, _, err := myjwt.GenerateToken("U2024010112345678901")
+	if err != nil {
+		t.Fatal(err)
+	}
+	global.CONFIG.JwtConfig.SigningKey = "another-key"
+	if _, err := myjwt.ParseToken(token); !errors.Is(err, myjwt.ErrTokenInvalid) {
+		t.Fatalf("期望ErrTokenInvalid，实际为%v", err)
+	}
+}
+
+func TestParseExpiredToken(t *testing.T) {
+	initJwtConfig()
+	global.CONFIG.JwtConfig.ExpiresTime = -1
+	token, _, err := myjwt.GenerateToken("U2024010112345678901")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := myjwt.ParseToken(token); !errors.Is(err, myjwt.ErrTokenExpired) {
+		t.Fatalf("期望ErrTokenExpired，实际为%v", err)
+	}
+}
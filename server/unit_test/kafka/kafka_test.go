@@ -22,8 +22,8 @@ func TestKafka(t *testing.T) {
 	myredis.InitRedis()
 
 	// 5. kafka初始化
-	chat.InitKafka()
 	kafka.KafkaService.KafkaInit()
+	chat.ChatServer.SetTransport(chat.NewKafkaTransport())
 	defer kafka.KafkaService.KafkaClose()
 	kafka.KafkaService.CreateTopic()
 }
@@ -0,0 +1,31 @@
+package password
+
+import (
+	"Kama-Chat/lib/password"
+	"testing"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	hashed, err := password.Hash("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !password.IsHashed(hashed) {
+		t.Fatalf("哈希格式不正确: %s", hashed)
+	}
+	if ok, needRehash := password.Verify(hashed, "123456"); !ok || needRehash {
+		t.Fatalf("校验结果不正确 ok=%v needRehash=%v", ok, needRehash)
+	}
+	if ok, _ := password.Verify(hashed, "1234567"); ok {
+		t.Fatal("错误密码校验通过")
+	}
+}
+
+func TestVerifyLegacyPlaintext(t *testing.T) {
+	if ok, needRehash := password.Verify("123456", "123456"); !ok || !needRehash {
+		t.Fatalf("明文密码应校验通过并需要迁移 ok=%v needRehash=%v", ok, needRehash)
+	}
+	if ok, _ := password.Verify("123456", "654321"); ok {
+		t.Fatal("错误密码校验通过")
+	}
+}
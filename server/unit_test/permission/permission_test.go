@@ -0,0 +1,105 @@
+package permission
+
+import (
+	"Kama-Chat/global"
+	"Kama-Chat/model"
+	"Kama-Chat/utils/enum"
+	"Kama-Chat/utils/validate"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestCheckPermission(t *testing.T) {
+	cases := []struct {
+		role       int8
+		permission string
+		want       bool
+	}{
+		{enum.ROLE_USER, validate.PermUserList, false},
+		{enum.ROLE_ADMIN, validate.PermUserList, true},
+		{enum.ROLE_ADMIN, validate.PermGroupDelete, true},
+		{enum.ROLE_ADMIN, validate.PermUserSetAdmin, false},
+		{enum.ROLE_SUPER_ADMIN, validate.PermUserSetAdmin, true},
+		{enum.ROLE_SUPER_ADMIN, "unknown:permission", false},
+	}
+	for _, c := range cases {
+		if got := validate.CheckPermission(c.role, c.permission); got != c.want {
+			t.Fatalf("role=%d permission=%s 期望%v 实际%v", c.role, c.permission, c.want, got)
+		}
+	}
+}
+
+func TestCheckUserIsAdminOrNot(t *testing.T) {
+	global.CONFIG.AdminConfig.SuperAdminTelephones = []string{"13800000000"}
+	if role := validate.CheckUserIsAdminOrNot(model.UserInfo{Telephone: "13800000000"}); role != enum.ROLE_SUPER_ADMIN {
+		t.Fatalf("配置的手机号应为超级管理员，实际%d", role)
+	}
+	if role := validate.CheckUserIsAdminOrNot(model.UserInfo{Telephone: "13800000001"}); role != enum.ROLE_USER {
+		t.Fatalf("其余手机号应为普通用户，实际%d", role)
+	}
+}
+
+func TestCheckGroupPermission(t *testing.T) {
+	cases := []struct {
+		role       int8
+		permission string
+		want       bool
+	}{
+		{enum.GROUP_MEMBER, validate.GroupPermKick, false},
+		{enum.GROUP_ADMIN, validate.GroupPermKick, true},
+		{enum.GROUP_ADMIN, validate.GroupPermMute, true},
+		{enum.GROUP_ADMIN, validate.GroupPermEditInfo, true},
+		{enum.GROUP_ADMIN, validate.GroupPermApproveJoin, true},
+		{enum.GROUP_ADMIN, validate.GroupPermPin, true},
+		{enum.GROUP_MEMBER, validate.GroupPermPin, false},
+		{enum.GROUP_ADMIN, validate.GroupPermSetAdmin, false},
+		{enum.GROUP_OWNER, validate.GroupPermSetAdmin, true},
+		{enum.GROUP_ADMIN, validate.GroupPermDismiss, false},
+		{enum.GROUP_ADMIN, validate.GroupPermTransfer, false},
+		{enum.GROUP_OWNER, validate.GroupPermTransfer, true},
+		{enum.GROUP_OWNER, "unknown:permission", false},
+	}
+	for _, c := range cases {
+		if got := validate.CheckGroupPermission(c.role, c.permission); got != c.want {
+			t.Fatalf("role=%d permission=%s 期望%v 实际%v", c.role, c.permission, c.want, got)
+		}
+	}
+}
+
+func TestCheckCanManageGroupMember(t *testing.T) {
+	owner := model.GroupMember{UserId: "U1", Role: enum.GROUP_OWNER}
+	admin := model.GroupMember{UserId: "U2", Role: enum.GROUP_ADMIN}
+	member := model.GroupMember{UserId: "U3", Role: enum.GROUP_MEMBER}
+	if _, ret := validate.CheckCanManageGroupMember(owner, admin); ret != 0 {
+		t.Fatal("群主应能管理管理员")
+	}
+	if _, ret := validate.CheckCanManageGroupMember(admin, member); ret != 0 {
+		t.Fatal("管理员应能管理普通成员")
+	}
+	if _, ret := validate.CheckCanManageGroupMember(admin, model.GroupMember{UserId: "U4", Role: enum.GROUP_ADMIN}); ret != -2 {
+		t.Fatal("管理员不能管理其他管理员")
+	}
+	if _, ret := validate.CheckCanManageGroupMember(admin, owner); ret != -2 {
+		t.Fatal("管理员不能管理群主")
+	}
+	if _, ret := validate.CheckCanManageGroupMember(owner, owner); ret != -2 {
+		t.Fatal("不能管理自己")
+	}
+}
+
+func TestIsMuted(t *testing.T) {
+	now := time.Now()
+	if validate.IsMuted(model.GroupMember{}, now) {
+		t.Fatal("没有禁言截止时间时不应处于禁言中")
+	}
+	if !validate.IsMuted(model.GroupMember{MuteUntil: sql.NullTime{Time: now.Add(time.Minute), Valid: true}}, now) {
+		t.Fatal("截止时间之前应处于禁言中")
+	}
+	if validate.IsMuted(model.GroupMember{MuteUntil: sql.NullTime{Time: now.Add(-time.Minute), Valid: true}}, now) {
+		t.Fatal("截止时间之后应自动解除禁言")
+	}
+	if !validate.IsMuted(model.GroupMember{MuteUntil: sql.NullTime{Time: validate.MuteForever, Valid: true}}, now) {
+		t.Fatal("永久禁言应一直处于禁言中")
+	}
+}
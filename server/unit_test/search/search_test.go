@@ -0,0 +1,34 @@
+package search
+
+import (
+	"Kama-Chat/lib/search"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	terms := search.Terms(` 周末 +"聚餐"  周末 Go go `)
+	if !reflect.DeepEqual(terms, []string{"周末", "聚餐", "Go"}) {
+		t.Fatalf("搜索词应去掉运算符和重复的词: %v", terms)
+	}
+	if len(search.Terms(`"" + -`)) != 0 {
+		t.Fatal("只有运算符时不应有搜索词")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := search.Highlight("明天一起去聚餐吧", []string{"聚餐"})
+	if got != "明天一起去<em>聚餐</em>吧" {
+		t.Fatalf("高亮结果不对: %s", got)
+	}
+	got = search.Highlight("Hello <b>GO</b> and go", []string{"go"})
+	if got != "Hello &lt;b&gt;<em>GO</em>&lt;/b&gt; and <em>go</em>" {
+		t.Fatalf("应忽略大小写并转义html: %s", got)
+	}
+	long := strings.Repeat("前", 100) + "关键词" + strings.Repeat("后", 100)
+	got = search.Highlight(long, []string{"关键词"})
+	if !strings.HasPrefix(got, "...") || !strings.HasSuffix(got, "...") || !strings.Contains(got, "<em>关键词</em>") {
+		t.Fatalf("长内容应截取匹配位置附近: %s", got)
+	}
+}
@@ -0,0 +1,49 @@
+package testdb
+
+import (
+	"Kama-Chat/global"
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	myredis "Kama-Chat/lib/redis"
+	"github.com/alicebob/miniredis/v2"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// DsnEnv 测试库的连接串，测试会删除并重建其中的表，不要指向正在使用的数据库
+// 例如 root:123456@tcp(127.0.0.1:3306)/kama_chat_test?charset=utf8mb4&parseTime=True&loc=Local
+const DsnEnv = "KAMA_CHAT_TEST_MYSQL_DSN"
+
+// Open 连接MySQL测试库并重建指定的表，同时初始化日志和使用miniredis的Redis，未设置测试库时跳过测试
+func Open(t *testing.T, tables ...interface{}) *gorm.DB {
+	dsn := os.Getenv(DsnEnv)
+	if dsn == "" {
+		t.Skipf("未设置%s，跳过需要MySQL的测试", DsnEnv)
+	}
+	global.CONFIG.LogConfig.LogPath = filepath.Join(t.TempDir(), "test.log")
+	zlog.InitLogger()
+
+	server := miniredis.RunT(t)
+	host, port, _ := net.SplitHostPort(server.Addr())
+	global.CONFIG.RedisConfig.Host = host
+	global.CONFIG.RedisConfig.Port, _ = strconv.Atoi(port)
+	myredis.InitRedis()
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrator().DropTable(tables...); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	dao.GormDB = db
+	return db
+}
@@ -5,4 +5,13 @@ const (
 	SYSTEM_ERROR  = "系统错误，请联系工作人员" // 系统错误
 	FILE_MAX_SIZE = 50000          // 文件最大大小
 	REDIS_TIMEOUT = 1              // redis timeout
+	RECALLED_TEXT = "该消息已被撤回"      // 撤回后消息内容替换成的提示
+
+	MESSAGE_PAGE_SIZE  = 30  // 聊天记录默认每页条数
+	MESSAGE_PAGE_MAX   = 100 // 聊天记录每页最大条数
+	MESSAGE_CACHE_SIZE = 200 // redis中每个会话缓存的最近消息条数
+
+	VOICE_MAX_SIZE      = 2 << 20 // 语音文件最大字节数
+	VOICE_MAX_DURATION  = 60      // 语音最长秒数
+	VOICE_WAVEFORM_SIZE = 100     // 语音波形最多的采样点数，每个采样点取值0-100
 )
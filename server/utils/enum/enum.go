@@ -38,6 +38,8 @@ const (
 	Unsent = iota
 	// 已发送
 	Sent
+	// 已读，只用于单聊，群聊的已读情况按成员的已读进度计算
+	Read
 )
 
 // message_type_enum 消息类型
@@ -49,4 +51,121 @@ const (
 	File
 	// 通话
 	AudioOrVideo
+	// 系统通知，由服务端在群里发出，例如群主转让，客户端不能发送
+	System
+)
+
+// user_role_enum 用户角色，对应UserInfo.IsAdmin
+const (
+	// 普通用户
+	ROLE_USER = iota
+	// 管理员
+	ROLE_ADMIN
+	// 超级管理员
+	ROLE_SUPER_ADMIN
+)
+
+// group_role_enum 群内角色，对应GroupMember.Role
+const (
+	// 普通成员
+	GROUP_MEMBER = iota
+	// 管理员
+	GROUP_ADMIN
+	// 群主
+	GROUP_OWNER
+)
+
+// delivery_status_enum 设备投递状态
+const (
+	// 已推送，等待客户端确认
+	DeliveryPending = iota
+	// 客户端已确认
+	DeliveryAcked
+)
+
+// ws_event_enum websocket事件帧类型，不带event的帧是聊天消息
+// 事件帧只在连接上实时传递，不会写入message表
+const (
+	// 确认收到消息
+	EventAck = "ack"
+	// 已读回执
+	EventRead = "read"
+	// 正在输入，客户端发送后转发给会话另一方或群成员
+	EventTyping = "typing"
+	// 查询在线状态，服务端只回复给发起查询的设备
+	EventPresence = "presence"
+	// 好友上线，由服务端推送
+	EventOnline = "online"
+	// 好友下线，由服务端推送
+	EventOffline = "offline"
+	// 连接建立成功，由服务端推送
+	EventLogin = "login"
+	// 设备已退出登录，由服务端推送
+	EventLogout = "logout"
+	// 消息被撤回，由服务端推送给会话参与者
+	EventRecall = "recall"
+	// 消息被编辑，由服务端推送给会话参与者
+	EventEdit = "edit"
+	// 群聊消息被置顶，由服务端推送给群成员
+	EventPin = "pin"
+	// 群聊消息被取消置顶，由服务端推送给群成员
+	EventUnpin = "unpin"
+	// 消息被自己删除，由服务端推送给自己的其他设备
+	EventDelete = "delete"
+	// 添加表情回应，客户端发送后广播给会话参与者
+	EventAddReaction = "add_reaction"
+	// 取消表情回应，客户端发送后广播给会话参与者
+	EventRemoveReaction = "remove_reaction"
+	// 消息已保存，由服务端推送给发送者，重发的消息返回第一次保存的结果
+	EventSendAck = "send_ack"
+	// 消息被拒绝，由服务端推送给发送消息的设备
+	EventError = "error"
+	// 有新的加群申请，由服务端推送给群主和管理员
+	EventGroupApply = "group_apply"
+	// 加群申请已处理，由服务端推送给申请人和群主、管理员
+	EventGroupApplyResult = "group_apply_result"
+)
+
+// ws_error_code_enum websocket错误帧的错误码
+const (
+	// 消息格式或内容不合法
+	ErrInvalidMessage = "invalid_message"
+	// 消息中的发送者与连接的登录用户不一致
+	ErrSenderMismatch = "sender_mismatch"
+	// 发送者或接收者的账号已被禁用
+	ErrUserDisabled = "user_disabled"
+	// 接收者或操作的消息不存在
+	ErrNotFound = "not_found"
+	// 对方不是好友
+	ErrNotFriend = "not_friend"
+	// 已拉黑对方或被对方拉黑
+	ErrBlacklisted = "blacklisted"
+	// 不在群聊中
+	ErrNotGroupMember = "not_group_member"
+	// 群聊已被禁用
+	ErrGroupDisabled = "group_disabled"
+	// 群聊已解散
+	ErrGroupDismissed = "group_dismissed"
+	// 发送者在群里被禁言
+	ErrMuted = "muted"
+	// 群聊开启了全员禁言
+	ErrGroupMuted = "group_muted"
+	// 服务器繁忙，稍后重发
+	ErrBusy = "busy"
+	// 服务器内部错误
+	ErrSystem = "system_error"
+	// 帧不是合法的信封格式
+	ErrInvalidFrame = "invalid_frame"
+	// 未知的帧类型
+	ErrUnknownType = "unknown_type"
+	// 无权对该消息进行操作
+	ErrForbidden = "forbidden"
+)
+
+// ws_frame_type_enum 信封帧的类型，事件帧的类型就是事件名
+const (
+	// 聊天消息，客户端发送的和服务端推送的都是这个类型
+	FrameMessage = "message"
+	// 服务端推送的文本通知，例如登录欢迎语
+	FrameNotice = "notice"
 )
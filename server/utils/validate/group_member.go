@@ -0,0 +1,85 @@
+package validate
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// GetGroupMemberIds 获取群成员的uuid，按入群时间排序
+func GetGroupMemberIds(groupId string) ([]string, string, int) {
+	var memberIds []string
+	if res := dao.GormDB.Model(&model.GroupMember{}).Where("group_id = ?", groupId).
+		Order("joined_at ASC, id ASC").Pluck("user_id", &memberIds); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	return memberIds, "", 0
+}
+
+// GetJoinedGroupIds 获取用户当前所在的群聊id
+func GetJoinedGroupIds(userId string) ([]string, string, int) {
+	var groupIds []string
+	if res := dao.GormDB.Model(&model.GroupMember{}).Where("user_id = ?", userId).
+		Pluck("group_id", &groupIds); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	return groupIds, "", 0
+}
+
+// GetGroupMember 获取用户在群里的成员记录，不在群里时返回-2
+func GetGroupMember(groupId string, userId string) (model.GroupMember, string, int) {
+	var member model.GroupMember
+	if res := dao.GormDB.First(&member, "group_id = ? AND user_id = ?", groupId, userId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return member, "你不在该群聊中", -2
+		}
+		zlog.Error(res.Error.Error())
+		return member, constants.SYSTEM_ERROR, -1
+	}
+	return member, "", 0
+}
+
+// MuteForever 永久禁言时的禁言截止时间
+var MuteForever = time.Date(9999, 12, 31, 23, 59, 59, 0, time.Local)
+
+// IsMuted 判断成员在某一时刻是否处于禁言中，禁言截止时间过后自动解除
+func IsMuted(member model.GroupMember, now time.Time) bool {
+	return member.MuteUntil.Valid && member.MuteUntil.Time.After(now)
+}
+
+// ExpireMute 禁言到期后清除禁言截止时间，并把群聊联系人状态从禁言恢复为正常
+// 在读到已到期的禁言时调用，例如成员发言、查看成员列表时，失败不影响本次操作
+func ExpireMute(member model.GroupMember) {
+	if res := dao.GormDB.Model(&model.GroupMember{}).
+		Where("id = ? AND mute_until = ?", member.Id, member.MuteUntil).
+		Update("mute_until", nil); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("user_id = ? AND contact_id = ? AND status = ?", member.UserId, member.GroupId, enum.SILENCE).
+		Updates(map[string]interface{}{
+			"status":    enum.NORMAL_,
+			"update_at": time.Now(),
+		}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+}
+
+// GetGroupManagerIds 获取群主和管理员的uuid
+func GetGroupManagerIds(groupId string) ([]string, string, int) {
+	var managerIds []string
+	if res := dao.GormDB.Model(&model.GroupMember{}).Where("group_id = ? AND role >= ?", groupId, enum.GROUP_ADMIN).
+		Pluck("user_id", &managerIds); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	return managerIds, "", 0
+}
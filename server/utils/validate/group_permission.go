@@ -0,0 +1,60 @@
+package validate
+
+import (
+	"Kama-Chat/model"
+	"Kama-Chat/utils/enum"
+)
+
+// 群内权限点，群聊的管理操作按操作者在群里的角色判断，与系统管理员无关
+const (
+	GroupPermKick        = "group:kick"         // 移出成员
+	GroupPermMute        = "group:mute"         // 禁言成员
+	GroupPermEditInfo    = "group:edit_info"    // 修改群资料和群公告
+	GroupPermApproveJoin = "group:approve_join" // 审批加群申请
+	GroupPermPin         = "group:pin"          // 置顶、取消置顶消息
+	GroupPermSetAdmin    = "group:set_admin"    // 设置、取消管理员
+	GroupPermTransfer    = "group:transfer"     // 转让群主
+	GroupPermDismiss     = "group:dismiss"      // 解散群聊
+)
+
+// groupPermissionMinRole 每个群内权限点要求的最低群内角色，未登记的权限点任何角色都没有
+var groupPermissionMinRole = map[string]int8{
+	GroupPermKick:        enum.GROUP_ADMIN,
+	GroupPermMute:        enum.GROUP_ADMIN,
+	GroupPermEditInfo:    enum.GROUP_ADMIN,
+	GroupPermApproveJoin: enum.GROUP_ADMIN,
+	GroupPermPin:         enum.GROUP_ADMIN,
+	GroupPermSetAdmin:    enum.GROUP_OWNER,
+	GroupPermTransfer:    enum.GROUP_OWNER,
+	GroupPermDismiss:     enum.GROUP_OWNER,
+}
+
+// CheckGroupPermission 判断群内角色是否拥有权限点
+func CheckGroupPermission(role int8, permission string) bool {
+	minRole, ok := groupPermissionMinRole[permission]
+	return ok && role >= minRole
+}
+
+// CheckGroupOperator 检查操作者是否在群里且拥有权限点，通过时返回操作者的成员记录
+func CheckGroupOperator(groupId string, operatorId string, permission string) (model.GroupMember, string, int) {
+	operator, message, ret := GetGroupMember(groupId, operatorId)
+	if ret != 0 {
+		return operator, message, ret
+	}
+	if !CheckGroupPermission(operator.Role, permission) {
+		return operator, "没有权限进行该操作", -2
+	}
+	return operator, "", 0
+}
+
+// CheckCanManageGroupMember 检查操作者能否管理目标成员
+// 不能操作自己，也只能操作群内角色低于自己的成员，即管理员只能管理普通成员，群主可以管理管理员
+func CheckCanManageGroupMember(operator model.GroupMember, target model.GroupMember) (string, int) {
+	if operator.UserId == target.UserId {
+		return "不能对自己进行该操作", -2
+	}
+	if operator.Role <= target.Role {
+		return "只能管理角色低于自己的成员", -2
+	}
+	return "", 0
+}
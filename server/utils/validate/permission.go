@@ -0,0 +1,74 @@
+package validate
+
+import (
+	"Kama-Chat/initialize/dao"
+	"Kama-Chat/initialize/zlog"
+	"Kama-Chat/model"
+	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
+	"errors"
+	"gorm.io/gorm"
+)
+
+// 权限点，管理接口通过 middleware.RequirePermission 声明需要的权限
+const (
+	PermUserList     = "user:list"      // 查看用户列表
+	PermUserStatus   = "user:status"    // 启用/禁用用户
+	PermUserDelete   = "user:delete"    // 删除用户
+	PermUserSetAdmin = "user:set_admin" // 设置管理员
+	PermGroupList    = "group:list"     // 查看群聊列表
+	PermGroupDelete  = "group:delete"   // 删除群聊
+	PermGroupStatus  = "group:status"   // 启用/禁用群聊
+)
+
+// permissionMinRole 每个权限点要求的最低角色，未登记的权限点任何角色都没有
+var permissionMinRole = map[string]int8{
+	PermUserList:     enum.ROLE_ADMIN,
+	PermUserStatus:   enum.ROLE_ADMIN,
+	PermUserDelete:   enum.ROLE_ADMIN,
+	PermUserSetAdmin: enum.ROLE_SUPER_ADMIN,
+	PermGroupList:    enum.ROLE_ADMIN,
+	PermGroupDelete:  enum.ROLE_ADMIN,
+	PermGroupStatus:  enum.ROLE_ADMIN,
+}
+
+// CheckPermission 判断角色是否拥有权限点
+func CheckPermission(role int8, permission string) bool {
+	minRole, ok := permissionMinRole[permission]
+	return ok && role >= minRole
+}
+
+// GetUserRole 获取用户当前角色
+// 每次都从数据库读取，这样降级、禁用后立即生效；被禁用的用户按普通用户处理
+func GetUserRole(uuid string) (int8, string, int) {
+	var user model.UserInfo
+	if res := dao.GormDB.First(&user, "uuid = ?", uuid); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return enum.ROLE_USER, "用户不存在", -2
+		}
+		zlog.Error(res.Error.Error())
+		return enum.ROLE_USER, constants.SYSTEM_ERROR, -1
+	}
+	if user.Status == enum.DISABLE {
+		return enum.ROLE_USER, "", 0
+	}
+	return user.IsAdmin, "", 0
+}
+
+// CheckCanManageUsers 检查操作者能否管理目标用户
+// 不能操作自己，也只能操作角色低于自己的用户，即管理员只能管理普通用户，超级管理员可以管理管理员
+func CheckCanManageUsers(operatorId string, targets []model.UserInfo) (string, int) {
+	operatorRole, message, ret := GetUserRole(operatorId)
+	if ret != 0 {
+		return message, ret
+	}
+	for _, target := range targets {
+		if target.Uuid == operatorId {
+			return "不能对自己执行该操作", -2
+		}
+		if target.IsAdmin >= operatorRole {
+			return "无权操作同级或更高级别的管理员", -2
+		}
+	}
+	return "", 0
+}
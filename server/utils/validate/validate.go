@@ -1,10 +1,12 @@
 package validate
 
 import (
+	"Kama-Chat/global"
 	"Kama-Chat/initialize/dao"
 	"Kama-Chat/initialize/zlog"
 	"Kama-Chat/model"
 	"Kama-Chat/utils/constants"
+	"Kama-Chat/utils/enum"
 	"errors"
 	"gorm.io/gorm"
 	"regexp"
@@ -46,7 +48,25 @@ func CheckEmailValid(email string) bool {
 	return match
 }
 
-// CheckUserIsAdminOrNot 检验用户是否为管理员
+// CheckPasswordValid 校验密码长度
+// bcrypt最多只处理72字节，超出部分会被忽略，所以直接拒绝
+func CheckPasswordValid(password string) (string, int) {
+	if len(password) < 6 {
+		return "密码长度不能少于6位", -2
+	}
+	if len(password) > 72 {
+		return "密码长度不能超过72位", -2
+	}
+	return "", 0
+}
+
+// CheckUserIsAdminOrNot 计算新注册用户的角色
+// 配置了super_admin_telephones的手机号注册后直接成为超级管理员，其余都是普通用户，管理员只能由超级管理员设置
 func CheckUserIsAdminOrNot(user model.UserInfo) int8 {
-	return user.IsAdmin
+	for _, telephone := range global.CONFIG.AdminConfig.SuperAdminTelephones {
+		if telephone == user.Telephone {
+			return enum.ROLE_SUPER_ADMIN
+		}
+	}
+	return enum.ROLE_USER
 }